@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lukinairina90/crud_movies/internal/domain"
@@ -9,8 +13,13 @@ import (
 	"github.com/lukinairina90/crud_movies/internal/service"
 	"github.com/lukinairina90/crud_movies/internal/transport/rest"
 	"github.com/lukinairina90/crud_movies/pkg/config"
+	"github.com/lukinairina90/crud_movies/pkg/crypt"
 	"github.com/lukinairina90/crud_movies/pkg/database"
 	"github.com/lukinairina90/crud_movies/pkg/hash"
+	"github.com/lukinairina90/crud_movies/pkg/jwks"
+	"github.com/lukinairina90/crud_movies/pkg/mail"
+	"github.com/lukinairina90/crud_movies/pkg/oidc"
+	"github.com/lukinairina90/crud_movies/pkg/sign"
 	"github.com/lukinairina90/in_memory_cache/generic_cache"
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
@@ -41,33 +50,161 @@ func main() {
 		logrus.Fatalf("failed to connection db: %s", err.Error())
 	}
 
-	tokenSecret := []byte("sample secret")
-
 	defer db.Close()
 
 	// init deps
-	hasher := hash.NewMD5Hasher("salt")
+	var keySet *jwks.KeySet
+	if cfg.JWTSigningKeyFile != "" {
+		keySet, err = jwks.LoadKeySet(cfg.JWTSigningKeyFile, cfg.JWTVerificationKeyFiles...)
+	} else {
+		logrus.Warn("JWT_SIGNING_KEY_FILE is not set, tokens are signed with a temporary key")
+		keySet, err = jwks.GenerateKeySet()
+	}
+	if err != nil {
+		logrus.Fatalf("failed to load jwt keys: %s", err.Error())
+	}
+
+	argon2Hasher := hash.NewArgon2idHasher(hash.DefaultArgon2idParams)
+	bcryptHasher := hash.NewBcryptHasher(cfg.BcryptCost)
+	md5Hasher := hash.NewMD5Hasher("salt") // legacy hashes are verified and rehashed on sign-in
+
+	hasher := hash.NewHasher(argon2Hasher, bcryptHasher, md5Hasher)
+	if cfg.PasswordHash == "bcrypt" {
+		hasher = hash.NewHasher(bcryptHasher, argon2Hasher, md5Hasher)
+	}
+	var mailer service.Mailer
+	switch cfg.MailDriver {
+	case "smtp":
+		mailer = mail.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.MailFrom)
+	case "memory":
+		mailer = mail.NewMemorySender(cfg.MailFrom)
+	default:
+		mailer, err = mail.NewFileSender(cfg.MailDir, cfg.MailFrom)
+		if err != nil {
+			logrus.Fatalf("failed to init mail sender: %s", err.Error())
+		}
+	}
+
 	movieCache := generic_cache.New[string, domain.Movie]()
+	cursorSigner := sign.NewSigner([]byte(cfg.CursorSecret))
 
-	movieRepository := repository.NewMovie(db)
+	var movieRepository service.MoviesRepository = repository.NewMovie(db)
+	if cfg.SearchMode == "ilike" {
+		movieRepository = repository.NewILikeMovie(db)
+	}
 	//cachedMovieRepo := repository.NewCachedMovie(movieRepository, movieCache)
 
-	movieService := service.NewMovie(movieRepository, movieCache)
+	genresRepository := repository.NewGenres(db)
+
+	movieService := service.NewMovie(movieRepository, repository.NewCast(db), repository.NewCrew(db), genresRepository, repository.NewRatings(db), movieCache, cursorSigner)
 	moviesTransport := rest.NewMovie(movieService)
 
+	genresService := service.NewGenres(genresRepository)
+	genresTransport := rest.NewGenres(genresService)
+
+	peopleService := service.NewPeople(repository.NewPeople(db))
+	peopleTransport := rest.NewPeople(peopleService)
+
+	reviewsService := service.NewReviews(repository.NewReviews(db))
+	reviewsTransport := rest.NewReviews(reviewsService)
+
 	usersRepository := repository.NewUsers(db)
 	tokensRepository := repository.NewTokens(db)
-	usersService := service.NewUsers(usersRepository, tokensRepository, hasher, tokenSecret, cfg.TokenTTL)
+	revocationsRepository := repository.NewRevocations(db)
+	passwordResetsRepository := repository.NewPasswordResets(db)
+	twoFactorRepository := repository.NewTwoFactor(db)
 
-	authTransport := rest.NewAuth(usersService)
+	totpCipher, err := crypt.NewCipher([]byte(cfg.TOTPEncryptionKey))
+	if err != nil {
+		logrus.Fatalf("failed to init totp cipher: %s", err.Error())
+	}
+
+	var loginAttemptsRepository service.LoginAttemptsRepository = repository.NewLoginAttempts(db)
+	if cfg.LoginAttemptsStore == "memory" {
+		loginAttemptsRepository = repository.NewMemoryLoginAttempts()
+	}
+	loginThrottle := service.NewLoginThrottle(loginAttemptsRepository, service.LockoutPolicy{
+		AccountMaxFailures: cfg.LoginMaxFailures,
+		IPMaxFailures:      cfg.LoginMaxFailuresPerIP,
+		Window:             cfg.LoginFailureWindow,
+		BaseLockout:        cfg.LoginLockout,
+		MaxLockout:         cfg.LoginMaxLockout,
+	})
+
+	passwordResetThrottle := service.NewPasswordResetThrottle(loginAttemptsRepository, service.LockoutPolicy{
+		AccountMaxFailures: cfg.PasswordResetMaxPerEmail,
+		IPMaxFailures:      cfg.PasswordResetMaxPerIP,
+		Window:             cfg.PasswordResetWindow,
+		BaseLockout:        cfg.PasswordResetWindow,
+		MaxLockout:         cfg.PasswordResetWindow,
+	})
+
+	go loginThrottle.RunCleanup(context.Background(), cfg.LoginCleanupInterval)
+	go passwordResetThrottle.RunCleanup(context.Background(), cfg.LoginCleanupInterval)
+
+	usersService := service.NewUsers(usersRepository, tokensRepository, revocationsRepository, passwordResetsRepository, twoFactorRepository, totpCipher, hasher, loginThrottle, passwordResetThrottle, mailer, keySet, cfg.TokenTTL, cfg.AppURL, cfg.TOTPIssuer)
+
+	apiKeysService := service.NewAPIKeys(repository.NewAPIKeys(db), usersRepository, twoFactorRepository)
+
+	authTransport := rest.NewAuth(usersService, apiKeysService)
+	keysTransport := rest.NewKeys(keySet)
+	profileTransport := rest.NewProfile(usersService)
+	twoFactorTransport := rest.NewTwoFactor(usersService)
+	apiKeysTransport := rest.NewAPIKeys(apiKeysService)
+
+	var oidcTransport *rest.OIDC
+	if cfg.OIDCDiscoveryURL != "" {
+		if cfg.OIDCStateSecret == "" {
+			logrus.Fatal("OIDC_STATE_SECRET is required with OIDC_DISCOVERY_URL")
+		}
+
+		redirectURL := cfg.OIDCRedirectURL
+		if redirectURL == "" {
+			redirectURL = cfg.AppURL + "/auth/oidc/callback"
+		}
+
+		oidcClient := oidc.NewClient(oidc.Config{
+			DiscoveryURL: cfg.OIDCDiscoveryURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, &http.Client{Timeout: 10 * time.Second})
+
+		oidcService := service.NewOIDC(usersService, repository.NewIdentities(db), oidcClient,
+			sign.NewSigner([]byte(cfg.OIDCStateSecret)), cfg.OIDCProvider, cfg.OIDCAutoProvision)
+
+		// the login state cookie has to come back to the callback on the application's host
+		appURL, err := url.Parse(cfg.AppURL)
+		if err != nil {
+			logrus.Fatalf("invalid app url: %s", err.Error())
+		}
+
+		oidcTransport = rest.NewOIDC(oidcService, appURL.Hostname(), appURL.Scheme == "https")
+	}
 
 	// init routes
 	g := gin.New()
+	if err := g.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logrus.Fatalf("invalid trusted proxies: %s", err.Error())
+	}
+
 	g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	g.Use(rest.LoggingMiddleware())
+	g.Use(rest.LoggingMiddleware(), rest.ErrorMiddleware())
+	keysTransport.InjectRoutes(g)
 	authTransport.InjectRoutes(g)
+	if oidcTransport != nil {
+		oidcTransport.InjectRoutes(g)
+		oidcTransport.InjectLinkRoutes(g, authTransport.AuthMiddleware(), rest.RequireSession())
+	}
+	profileTransport.InjectRoutes(g, authTransport.AuthMiddleware(), rest.RequireSession())
+	twoFactorTransport.InjectRoutes(g, authTransport.AuthMiddleware(), rest.RequireSession())
+	apiKeysTransport.InjectRoutes(g, authTransport.AuthMiddleware(), rest.RequireSession())
 	moviesTransport.InjectRoutes(g, authTransport.AuthMiddleware())
+	peopleTransport.InjectRoutes(g, authTransport.AuthMiddleware())
+	genresTransport.InjectRoutes(g, authTransport.AuthMiddleware())
+	reviewsTransport.InjectRoutes(g, authTransport.AuthMiddleware())
 
 	fmt.Println("Server run...")
 	if err := g.Run(fmt.Sprintf(":%s", cfg.Port)); err != nil {
@@ -0,0 +1,14 @@
+CREATE TABLE revoked_tokens
+(
+    jti        VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE revoked_user_tokens
+(
+    user_id       INT REFERENCES users (id) ON DELETE CASCADE PRIMARY KEY,
+    issued_before TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP NOT NULL
+);
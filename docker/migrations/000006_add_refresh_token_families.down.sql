@@ -0,0 +1,2 @@
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN rotated_at, DROP COLUMN family_id;
\ No newline at end of file
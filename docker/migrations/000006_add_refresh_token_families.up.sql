@@ -0,0 +1,10 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id  VARCHAR(64),
+    ADD COLUMN rotated_at TIMESTAMP;
+
+-- every existing token starts a family of its own
+UPDATE refresh_tokens SET family_id = md5(token);
+
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
@@ -0,0 +1,2 @@
+DROP INDEX refresh_tokens_user_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN last_used_at, DROP COLUMN created_at, DROP COLUMN ip, DROP COLUMN user_agent;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN user_agent   VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN ip           VARCHAR(45)  NOT NULL DEFAULT '',
+    ADD COLUMN created_at   TIMESTAMP    NOT NULL DEFAULT now(),
+    ADD COLUMN last_used_at TIMESTAMP    NOT NULL DEFAULT now();
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
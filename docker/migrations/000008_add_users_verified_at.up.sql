@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN verified_at TIMESTAMP;
+
+-- accounts registered before email verification was introduced stay usable
+UPDATE users SET verified_at = registered_at;
@@ -0,0 +1,8 @@
+CREATE TABLE password_reset_tokens
+(
+    id         SERIAL UNIQUE                               NOT NULL,
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    token_hash VARCHAR(64)                                 NOT NULL UNIQUE,
+    created_at TIMESTAMP                                   NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP                                   NOT NULL
+);
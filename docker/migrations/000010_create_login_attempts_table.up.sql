@@ -0,0 +1,7 @@
+CREATE TABLE login_attempts
+(
+    key            VARCHAR(320) PRIMARY KEY,
+    failures       INT       NOT NULL,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until   TIMESTAMP
+);
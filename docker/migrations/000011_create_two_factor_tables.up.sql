@@ -0,0 +1,15 @@
+CREATE TABLE user_totp
+(
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE PRIMARY KEY,
+    secret     VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP,
+    last_step  BIGINT      NOT NULL DEFAULT 0
+);
+
+CREATE TABLE recovery_codes
+(
+    id        SERIAL UNIQUE                               NOT NULL,
+    user_id   INT REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    code_hash VARCHAR(64)                                 NOT NULL,
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys
+(
+    id           SERIAL UNIQUE                               NOT NULL,
+    user_id      INT REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    name         VARCHAR(100)                                NOT NULL,
+    prefix       VARCHAR(16)                                 NOT NULL,
+    key_hash     VARCHAR(64)                                 NOT NULL UNIQUE,
+    scopes       TEXT[]                                      NOT NULL,
+    created_at   TIMESTAMP                                   NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE user_identities
+(
+    id         SERIAL UNIQUE                               NOT NULL,
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    provider   VARCHAR(50)                                 NOT NULL,
+    subject    VARCHAR(255)                                NOT NULL,
+    email      VARCHAR(100)                                NOT NULL,
+    created_at TIMESTAMP                                   NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
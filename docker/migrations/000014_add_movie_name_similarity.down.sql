@@ -0,0 +1,3 @@
+DROP INDEX movie_name_trgm_idx;
+DROP FUNCTION normalize_title(TEXT);
+-- the extensions are left installed, they may have been there before the migration and other objects may use them
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is only STABLE as its dictionary may change, the wrapper pins the dictionary so it can be indexed
+CREATE FUNCTION normalize_title(title TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+AS
+$$
+SELECT lower(public.unaccent('public.unaccent'::regdictionary, title))
+$$;
+
+CREATE INDEX movie_name_trgm_idx ON movie USING GIN (normalize_title(name) gin_trgm_ops);
@@ -0,0 +1,2 @@
+DROP TABLE movie_cast;
+DROP TABLE people;
\ No newline at end of file
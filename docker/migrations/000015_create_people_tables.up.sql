@@ -0,0 +1,35 @@
+CREATE TABLE people
+(
+    id         SERIAL UNIQUE NOT NULL,
+    name       VARCHAR(255)  NOT NULL,
+    bio        TEXT          NOT NULL DEFAULT '',
+    created_at TIMESTAMP     NOT NULL DEFAULT now()
+);
+
+CREATE INDEX people_name_idx ON people (lower(name));
+
+CREATE TABLE movie_cast
+(
+    movie_id       INT REFERENCES movie (id) ON DELETE CASCADE  NOT NULL,
+    person_id      INT REFERENCES people (id) ON DELETE CASCADE NOT NULL,
+    character_name VARCHAR(255)                                 NOT NULL DEFAULT '',
+    billing        INT                                          NOT NULL,
+    PRIMARY KEY (movie_id, billing),
+    UNIQUE (movie_id, person_id, character_name)
+);
+
+CREATE INDEX movie_cast_person_idx ON movie_cast (person_id);
+
+-- split the actors strings into people, names that differ only in case are the same person
+INSERT INTO people (name)
+SELECT DISTINCT ON (lower(name)) name
+FROM (SELECT btrim(unnest(string_to_array(actors, ','))) AS name FROM movie) AS actor
+WHERE name <> ''
+ORDER BY lower(name), name;
+
+INSERT INTO movie_cast (movie_id, person_id, billing)
+SELECT m.id, p.id, row_number() OVER (PARTITION BY m.id ORDER BY a.ord)
+FROM movie m
+         CROSS JOIN LATERAL unnest(string_to_array(m.actors, ',')) WITH ORDINALITY AS a(name, ord)
+         JOIN people p ON lower(p.name) = lower(btrim(a.name))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+ALTER TABLE movie ADD COLUMN genre VARCHAR(20) NOT NULL DEFAULT '';
+
+UPDATE movie m
+SET genre = left(g.names, 20)
+FROM (SELECT mg.movie_id, string_agg(g.name, ', ' ORDER BY g.name) AS names
+      FROM movie_genres mg
+               JOIN genres g ON g.id = mg.genre_id
+      GROUP BY mg.movie_id) AS g
+WHERE g.movie_id = m.id;
+
+ALTER TABLE movie ALTER COLUMN genre DROP DEFAULT;
+
+DROP TABLE movie_genres;
+DROP TABLE genres;
\ No newline at end of file
@@ -0,0 +1,44 @@
+CREATE TABLE genres
+(
+    id         SERIAL UNIQUE      NOT NULL,
+    slug       VARCHAR(50) UNIQUE NOT NULL,
+    name       VARCHAR(50)        NOT NULL,
+    created_at TIMESTAMP          NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX genres_name_idx ON genres (lower(name));
+
+CREATE TABLE movie_genres
+(
+    movie_id INT REFERENCES movie (id) ON DELETE CASCADE NOT NULL,
+    genre_id INT REFERENCES genres (id)                  NOT NULL,
+    PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX movie_genres_genre_idx ON movie_genres (genre_id);
+
+-- split values like "Crime/Drama" or "crime, drama" into genres, the slug ignores case, diacritics and punctuation
+CREATE TEMPORARY TABLE movie_genre_names AS
+SELECT movie_id, name, slug
+FROM (SELECT m.id                                                                                 AS movie_id,
+             btrim(part)                                                                          AS name,
+             btrim(regexp_replace(lower(unaccent(btrim(part))), '[^a-z0-9]+', '-', 'g'), '-') AS slug
+      FROM movie m
+               CROSS JOIN LATERAL regexp_split_to_table(m.genre, '[,/;|&]') AS part) AS genre
+WHERE slug <> '';
+
+-- the most common spelling names the genre
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug) slug, initcap(name)
+FROM movie_genre_names
+GROUP BY slug, name
+ORDER BY slug, count(*) DESC, name;
+
+INSERT INTO movie_genres (movie_id, genre_id)
+SELECT DISTINCT n.movie_id, g.id
+FROM movie_genre_names n
+         JOIN genres g ON g.slug = n.slug;
+
+DROP TABLE movie_genre_names;
+
+ALTER TABLE movie DROP COLUMN genre;
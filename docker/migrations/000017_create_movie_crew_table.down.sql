@@ -0,0 +1 @@
+DROP TABLE movie_crew;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE movie_crew
+(
+    movie_id   INT REFERENCES movie (id) ON DELETE CASCADE  NOT NULL,
+    person_id  INT REFERENCES people (id) ON DELETE CASCADE NOT NULL,
+    department VARCHAR(20)                                  NOT NULL,
+    job        VARCHAR(100)                                 NOT NULL,
+    position   INT                                          NOT NULL,
+    PRIMARY KEY (movie_id, position),
+    UNIQUE (movie_id, person_id, job)
+);
+
+-- serves the crew filters of the movie listing and the removal of people
+CREATE INDEX movie_crew_person_idx ON movie_crew (person_id, department);
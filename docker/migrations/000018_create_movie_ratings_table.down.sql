@@ -0,0 +1,7 @@
+DROP TABLE movie_ratings;
+DROP FUNCTION update_movie_rating;
+
+ALTER TABLE movie
+    DROP COLUMN rating_score,
+    DROP COLUMN rating_sum,
+    DROP COLUMN rating_count;
\ No newline at end of file
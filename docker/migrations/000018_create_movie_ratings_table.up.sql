@@ -0,0 +1,45 @@
+ALTER TABLE movie
+    ADD COLUMN rating_count INT    NOT NULL DEFAULT 0,
+    ADD COLUMN rating_sum   BIGINT NOT NULL DEFAULT 0;
+
+-- Bayesian average: the votes are mixed with 10 prior votes of 6, so a movie with a couple of
+-- high votes doesn't outrank a movie with hundreds of good ones
+ALTER TABLE movie
+    ADD COLUMN rating_score DOUBLE PRECISION GENERATED ALWAYS AS ((rating_sum + 10 * 6.0) / (rating_count + 10)) STORED;
+
+CREATE INDEX movie_rating_score_idx ON movie (rating_score, id);
+
+CREATE TABLE movie_ratings
+(
+    movie_id INT REFERENCES movie (id) ON DELETE CASCADE NOT NULL,
+    user_id  INT REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    rating   SMALLINT                                    NOT NULL CHECK (rating BETWEEN 1 AND 10),
+    rated_at TIMESTAMP                                   NOT NULL DEFAULT now(),
+    PRIMARY KEY (movie_id, user_id)
+);
+
+CREATE INDEX movie_ratings_user_idx ON movie_ratings (user_id);
+
+-- keeps the vote count and sum of the movie up to date, so reads don't aggregate the ratings
+CREATE FUNCTION update_movie_rating() RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS
+$$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE movie SET rating_count = rating_count - 1, rating_sum = rating_sum - OLD.rating WHERE id = OLD.movie_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE movie SET rating_count = rating_count + 1, rating_sum = rating_sum + NEW.rating WHERE id = NEW.movie_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER movie_ratings_aggregate
+    AFTER INSERT OR UPDATE OF rating OR DELETE
+    ON movie_ratings
+    FOR EACH ROW
+EXECUTE FUNCTION update_movie_rating();
@@ -0,0 +1,3 @@
+DROP TABLE review_votes;
+DROP FUNCTION update_review_votes;
+DROP TABLE reviews;
\ No newline at end of file
@@ -0,0 +1,64 @@
+CREATE TABLE reviews
+(
+    id              SERIAL UNIQUE                                NOT NULL,
+    movie_id        INT REFERENCES movie (id) ON DELETE CASCADE  NOT NULL,
+    user_id         INT REFERENCES users (id) ON DELETE CASCADE  NOT NULL,
+    title           VARCHAR(200)                                 NOT NULL DEFAULT '',
+    body            TEXT                                         NOT NULL,
+    status          VARCHAR(10)                                  NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    moderation_note TEXT                                         NOT NULL DEFAULT '',
+    moderated_by    INT REFERENCES users (id) ON DELETE SET NULL,
+    moderated_at    TIMESTAMP,
+    helpful_count   INT                                          NOT NULL DEFAULT 0,
+    unhelpful_count INT                                          NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP                                    NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP                                    NOT NULL DEFAULT now(),
+    UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX reviews_movie_idx ON reviews (movie_id, status, created_at);
+CREATE INDEX reviews_user_idx ON reviews (user_id, created_at);
+-- the moderation queue
+CREATE INDEX reviews_status_idx ON reviews (status, created_at);
+
+CREATE TABLE review_votes
+(
+    review_id INT REFERENCES reviews (id) ON DELETE CASCADE NOT NULL,
+    user_id   INT REFERENCES users (id) ON DELETE CASCADE   NOT NULL,
+    helpful   BOOLEAN                                       NOT NULL,
+    voted_at  TIMESTAMP                                     NOT NULL DEFAULT now(),
+    PRIMARY KEY (review_id, user_id)
+);
+
+CREATE INDEX review_votes_user_idx ON review_votes (user_id);
+
+-- keeps the vote counts of the review up to date, like the movie ratings
+CREATE FUNCTION update_review_votes() RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS
+$$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE reviews
+        SET helpful_count   = helpful_count - OLD.helpful::INT,
+            unhelpful_count = unhelpful_count - (NOT OLD.helpful)::INT
+        WHERE id = OLD.review_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE reviews
+        SET helpful_count   = helpful_count + NEW.helpful::INT,
+            unhelpful_count = unhelpful_count + (NOT NEW.helpful)::INT
+        WHERE id = NEW.review_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER review_votes_count
+    AFTER INSERT OR UPDATE OF helpful OR DELETE
+    ON review_votes
+    FOR EACH ROW
+EXECUTE FUNCTION update_review_votes();
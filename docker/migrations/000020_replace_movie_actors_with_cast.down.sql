@@ -0,0 +1,10 @@
+ALTER TABLE movie ADD COLUMN actors VARCHAR(255);
+
+UPDATE movie SET actors = left(cast_names, 255) WHERE cast_names <> '';
+
+DROP TRIGGER people_cast_names ON people;
+DROP TRIGGER movie_cast_names ON movie_cast;
+DROP FUNCTION update_movie_cast_names;
+DROP FUNCTION movie_cast_names;
+
+ALTER TABLE movie DROP COLUMN cast_names;
\ No newline at end of file
@@ -0,0 +1,51 @@
+-- the names of the cast in billing order, kept up to date by the triggers for the search
+ALTER TABLE movie
+    ADD COLUMN cast_names TEXT NOT NULL DEFAULT '';
+
+CREATE FUNCTION movie_cast_names(movie_id INT) RETURNS TEXT
+    LANGUAGE sql STABLE
+AS
+$$
+SELECT coalesce(string_agg(p.name, ', ' ORDER BY c.billing), '')
+FROM movie_cast c
+         JOIN people p ON p.id = c.person_id
+WHERE c.movie_id = $1
+$$;
+
+CREATE FUNCTION update_movie_cast_names() RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'people' THEN
+        UPDATE movie SET cast_names = movie_cast_names(id) WHERE id IN (SELECT movie_id FROM movie_cast WHERE person_id = NEW.id);
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE movie SET cast_names = movie_cast_names(id) WHERE id = OLD.movie_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE movie SET cast_names = movie_cast_names(id) WHERE id = NEW.movie_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER movie_cast_names
+    AFTER INSERT OR UPDATE OR DELETE
+    ON movie_cast
+    FOR EACH ROW
+EXECUTE FUNCTION update_movie_cast_names();
+
+CREATE TRIGGER people_cast_names
+    AFTER UPDATE OF name
+    ON people
+    FOR EACH ROW
+EXECUTE FUNCTION update_movie_cast_names();
+
+UPDATE movie SET cast_names = movie_cast_names(id);
+
+ALTER TABLE movie DROP COLUMN actors;
@@ -0,0 +1,3 @@
+DROP INDEX movie_crew_person_idx;
+
+CREATE INDEX movie_crew_person_idx ON movie_crew (person_id, department);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- the crew filters of the movie listing match the job, not the department
+DROP INDEX movie_crew_person_idx;
+
+CREATE INDEX movie_crew_person_idx ON movie_crew (person_id, lower(job));
@@ -0,0 +1 @@
+-- the original names of the jobs are not kept, the crew stays with the jobs under their own names
\ No newline at end of file
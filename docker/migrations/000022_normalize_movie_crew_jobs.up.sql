@@ -0,0 +1,42 @@
+-- the jobs the movie listing filters by are stored under their own names, see domain.jobAliases
+CREATE TEMPORARY TABLE crew_job_aliases
+(
+    alias VARCHAR(100) PRIMARY KEY,
+    job   VARCHAR(100) NOT NULL
+);
+
+INSERT INTO crew_job_aliases (alias, job)
+VALUES ('director', 'Director'),
+       ('writer', 'Writer'),
+       ('screenwriter', 'Writer'),
+       ('screenplay', 'Writer'),
+       ('original music composer', 'Original Music Composer'),
+       ('music composer', 'Original Music Composer'),
+       ('composer', 'Original Music Composer'),
+       ('music', 'Original Music Composer'),
+       ('director of photography', 'Director of Photography'),
+       ('cinematographer', 'Director of Photography'),
+       ('cinematography', 'Director of Photography'),
+       ('dop', 'Director of Photography');
+
+-- a person credited with a job under several names keeps one credit: the one under the job's name,
+-- otherwise the first one
+DELETE
+FROM movie_crew c
+    USING crew_job_aliases a, movie_crew d, crew_job_aliases b
+WHERE lower(trim(c.job)) = a.alias
+  AND c.job <> a.job
+  AND d.movie_id = c.movie_id
+  AND d.person_id = c.person_id
+  AND d.position <> c.position
+  AND lower(trim(d.job)) = b.alias
+  AND b.job = a.job
+  AND (d.job = b.job OR d.position < c.position);
+
+UPDATE movie_crew c
+SET job = a.job
+FROM crew_job_aliases a
+WHERE lower(trim(c.job)) = a.alias
+  AND c.job <> a.job;
+
+DROP TABLE crew_job_aliases;
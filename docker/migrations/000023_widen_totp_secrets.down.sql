@@ -0,0 +1 @@
+-- the encrypted secrets don't fit the former width, the column is kept as it is
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX movie_search_idx;
+ALTER TABLE movie DROP COLUMN search;
\ No newline at end of file
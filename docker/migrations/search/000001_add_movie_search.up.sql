@@ -0,0 +1,9 @@
+-- the full-text search index, applied apart from the main migrations on backends with full-text search
+ALTER TABLE movie
+    ADD COLUMN search tsvector GENERATED ALWAYS AS (
+                setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+                setweight(to_tsvector('simple', cast_names), 'B') ||
+                setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+        ) STORED;
+
+CREATE INDEX movie_search_idx ON movie USING GIN (search);
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+const (
+	ScopeMoviesRead  = "movies:read"
+	ScopeMoviesWrite = "movies:write"
+)
+
+var (
+	ErrAPIKeyNotFound  = NewNotFoundError("api_key_not_found", "api key not found")
+	ErrAPIKeyNameTaken = NewConflictError("api_key_name_taken", "api key with this name already exists")
+	ErrInvalidAPIKey   = NewUnauthorizedError("invalid_api_key", "invalid or expired api key")
+)
+
+// APIKey lets machine clients act on behalf of the user within the scopes of the key.
+// Only the SHA-256 hash of the key is stored, Prefix is kept to tell the keys apart.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (k APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// CreatedAPIKey is returned once when the key is created, the key can't be shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=movies:read movies:write"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
+
+func (i CreateAPIKeyInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+var ErrTooManyAttempts = &Error{Kind: KindTooManyRequests, Code: "too_many_attempts", Message: "too many sign-in attempts"}
+
+// TooManyAttemptsError is returned while sign-in is locked out for the account or the client address.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LoginAttempts counts the failed sign-in attempts for a key, which is an account or a client address.
+type LoginAttempts struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// Locked reports whether sign-in is locked out at the given time.
+func (a LoginAttempts) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
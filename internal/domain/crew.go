@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+var ErrDuplicateCrewMember = NewConflictError("duplicate_crew_member", "the person has the job in the movie already")
+
+// Department groups the crew jobs, e.g. the directing department has the director and assistant directors.
+type Department string
+
+const (
+	DepartmentDirecting  Department = "directing"
+	DepartmentWriting    Department = "writing"
+	DepartmentProduction Department = "production"
+	DepartmentCamera     Department = "camera"
+	DepartmentEditing    Department = "editing"
+	DepartmentMusic      Department = "music"
+	DepartmentSound      Department = "sound"
+	DepartmentArt        Department = "art"
+)
+
+// The jobs the movie listing can be filtered by. A department has other jobs too,
+// e.g. the assistant directors, who are not the director of the movie.
+const (
+	JobDirector        = "Director"
+	JobWriter          = "Writer"
+	JobComposer        = "Original Music Composer"
+	JobCinematographer = "Director of Photography"
+)
+
+// jobAliases are the names the filterable jobs also go by, in lower case. The crew is stored with
+// the jobs under their own names, so the filters of the movie listing find every credit of a job.
+var jobAliases = map[string]string{
+	"director":                JobDirector,
+	"writer":                  JobWriter,
+	"screenwriter":            JobWriter,
+	"screenplay":              JobWriter,
+	"original music composer": JobComposer,
+	"music composer":          JobComposer,
+	"composer":                JobComposer,
+	"music":                   JobComposer,
+	"director of photography": JobCinematographer,
+	"cinematographer":         JobCinematographer,
+	"cinematography":          JobCinematographer,
+	"dop":                     JobCinematographer,
+}
+
+// jobDepartments are the departments of the filterable jobs.
+var jobDepartments = map[string]Department{
+	JobDirector:        DepartmentDirecting,
+	JobWriter:          DepartmentWriting,
+	JobComposer:        DepartmentMusic,
+	JobCinematographer: DepartmentCamera,
+}
+
+// CrewMember is a person working on a movie off screen. The crew is listed in the order the editors gave.
+type CrewMember struct {
+	PersonID   int64      `json:"person_id"`
+	Name       string     `json:"name"`
+	Department Department `json:"department"`
+	Job        string     `json:"job"`
+}
+
+type CrewMemberInput struct {
+	PersonID   int64      `json:"person_id" validate:"required,gt=0"`
+	Department Department `json:"department" validate:"required,oneof=directing writing production camera editing music sound art"`
+	Job        string     `json:"job" validate:"required,max=100"`
+}
+
+// SetCrewInput lists the whole crew of a movie, up to 200 people.
+type SetCrewInput struct {
+	Crew []CrewMemberInput `json:"crew" validate:"max=200,dive"`
+}
+
+// Validate checks the crew members, and that the filterable jobs are in their departments.
+func (i SetCrewInput) Validate() error {
+	if err := validate.Struct(i); err != nil {
+		return fieldValidationError(err)
+	}
+
+	fields := make(map[string]string)
+	for n, m := range i.WithCanonicalJobs().Crew {
+		if d, ok := jobDepartments[m.Job]; ok && m.Department != d {
+			fields[fmt.Sprintf("crew[%d].department", n)] = fmt.Sprintf("must be %s for the %s", d, m.Job)
+		}
+	}
+
+	if len(fields) > 0 {
+		return NewValidationError("validation_failed", "validation error", fields)
+	}
+
+	return nil
+}
+
+// WithCanonicalJobs returns the crew with the filterable jobs under their own names,
+// e.g. "composer" becomes "Original Music Composer". Other jobs are kept as given.
+func (i SetCrewInput) WithCanonicalJobs() SetCrewInput {
+	crew := make([]CrewMemberInput, len(i.Crew))
+	for n, m := range i.Crew {
+		m.Job = strings.TrimSpace(m.Job)
+		if job, ok := jobAliases[strings.ToLower(m.Job)]; ok {
+			m.Job = job
+		}
+
+		crew[n] = m
+	}
+
+	i.Crew = crew
+
+	return i
+}
+
+// CrewFilter selects the movies the person has had the job in, the job is matched ignoring case.
+type CrewFilter struct {
+	Job      string
+	PersonID int64
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorKind is the class of a domain error, the transport picks the response status by it.
+type ErrorKind int
+
+const (
+	KindValidation ErrorKind = iota + 1
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooManyRequests
+	KindUnsupportedMediaType
+)
+
+// Error is an error the client can act upon. Code is a stable machine readable identifier,
+// Message is meant for humans and Fields describes the invalid fields of validation errors.
+// Errors that aren't domain errors are internal and never shown to the client.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  map[string]string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewValidationError(code, message string, fields map[string]string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func NewUnauthorizedError(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func NewForbiddenError(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func NewNotFoundError(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func NewConflictError(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// validationError converts the errors of the validator to a validation error with a message per failed check.
+func validationError(err error) error {
+	var vErrs validator.ValidationErrors
+	if !errors.As(err, &vErrs) {
+		return err
+	}
+
+	fields := make(map[string]string, len(vErrs))
+	for _, fErr := range vErrs {
+		fields[fErr.ActualTag()] = fErr.Error()
+	}
+
+	return NewValidationError("validation_failed", "validation error", fields)
+}
+
+// fieldValidationError converts the errors of the validator to a validation error
+// with a readable message per invalid field, keyed by the JSON path of the field
+// such as "name" or "cast[0].person_id".
+func fieldValidationError(err error) error {
+	var vErrs validator.ValidationErrors
+	if !errors.As(err, &vErrs) {
+		return err
+	}
+
+	fields := make(map[string]string, len(vErrs))
+	for _, fErr := range vErrs {
+		path := fErr.Namespace()
+		if i := strings.IndexByte(path, '.'); i >= 0 {
+			path = path[i+1:]
+		}
+		fields[path] = fieldMessage(fErr)
+	}
+
+	return NewValidationError("validation_failed", "validation error", fields)
+}
+
+func fieldMessage(fErr validator.FieldError) string {
+	switch fErr.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		if fErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items", fErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fErr.Param())
+	case "min":
+		if fErr.Kind() == reflect.Slice && fErr.Param() == "1" {
+			return "must not be empty"
+		}
+		if fErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at least %s items", fErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", fErr.Param())
+	case "unique":
+		return "must not repeat items"
+	case "gt":
+		return "must be greater than " + fErr.Param()
+	case "gte":
+		return "must be at least " + fErr.Param()
+	case "lte":
+		return "must be at most " + fErr.Param()
+	case "url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fErr.Param()), ", ")
+	case "slug":
+		return "must contain only lowercase letters, digits and single dashes"
+	case "production_year":
+		return fmt.Sprintf("must be between %d and %d", FirstProductionYear, latestProductionYear())
+	default:
+		return fmt.Sprintf("failed on the '%s' check", fErr.Tag())
+	}
+}
+
+// jsonFieldName names the fields in validation errors by their JSON or query names.
+func jsonFieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	return f.Name
+}
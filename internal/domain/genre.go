@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	ErrGenreNotFound  = NewNotFoundError("genre_not_found", "genre not found")
+	ErrGenreTaken     = NewConflictError("genre_taken", "genre with such slug or name already exists")
+	ErrGenreInUse     = NewConflictError("genre_in_use", "genre is used by movies, merge it into another genre instead")
+	ErrGenreSelfMerge = NewValidationError("validation_failed", "validation error", map[string]string{"into": "must differ from the merged genre"})
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Genre is an entry of the genre taxonomy. Movies refer to genres by slug.
+type Genre struct {
+	ID   int64  `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type GenreInput struct {
+	Slug string `json:"slug" validate:"required,max=50,slug"`
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+func (i GenreInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// MergeGenreInput names the genre that takes over the movies of the merged one.
+type MergeGenreInput struct {
+	Into string `json:"into" validate:"required,max=50,slug"`
+}
+
+func (i MergeGenreInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// NewUnknownGenresError reports the genres of a movie that are missing from the taxonomy.
+func NewUnknownGenresError(slugs []string) *Error {
+	return NewValidationError("unknown_genre", "validation error", map[string]string{"genres": "unknown genres: " + strings.Join(slugs, ", ")})
+}
+
+func validateSlug(fl validator.FieldLevel) bool {
+	return slugPattern.MatchString(fl.Field().String())
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+var (
+	ErrAccountNotLinked = NewForbiddenError("account_not_linked", "no account is linked to this identity")
+	ErrInvalidOIDCState = NewValidationError("invalid_login_state", "invalid or expired login state", nil)
+	ErrOIDCLoginFailed  = NewUnauthorizedError("identity_provider_login_failed", "identity provider login failed")
+	ErrIdentityLinked   = NewConflictError("identity_already_linked", "the identity is linked to another account")
+)
+
+// Identity links a user to an account at an external identity provider.
+// Subject is the provider's id of the account, which unlike the email never changes.
+type Identity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// LinkIdentityInput confirms the signed-in user before an identity provider account is linked
+// to the user. Code is required when the user has two-factor authentication enabled.
+type LinkIdentityInput struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"max=32"`
+}
+
+func (i LinkIdentityInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
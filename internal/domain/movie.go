@@ -1,13 +1,353 @@
 package domain
 
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const DefaultMoviesLimit = 20
+
+const (
+	// FirstProductionYear is the year the oldest surviving film was shot.
+	FirstProductionYear = 1888
+	// maxYearsAhead allows announced movies that are still in production.
+	maxYearsAhead = 5
+)
+
+// MaxMovieDuplicates limits the near-duplicates reported for a movie.
+const MaxMovieDuplicates = 10
+
+var (
+	ErrMovieNotFound   = NewNotFoundError("movie_not_found", "movie not found")
+	ErrMovieNameTaken  = NewConflictError("movie_name_taken", "movie with such name already exists")
+	ErrPatchTestFailed = NewConflictError("patch_test_failed", "the movie doesn't match the test operation of the patch")
+	ErrInvalidCursor   = NewValidationError("invalid_cursor", "validation error", map[string]string{"cursor": "invalid cursor"})
+)
+
+// DuplicateMovieError is returned when the name of a created or updated movie is taken by another movie.
+type DuplicateMovieError struct {
+	ExistingID int64
+}
+
+func (e *DuplicateMovieError) Error() string {
+	return ErrMovieNameTaken.Error()
+}
+
+func (e *DuplicateMovieError) Unwrap() error {
+	return ErrMovieNameTaken
+}
+
 type ListMovie []Movie
 
+// Refs returns pointers to the movies of the list, which lets the details be filled in.
+func (l ListMovie) Refs() []*Movie {
+	movies := make([]*Movie, 0, len(l))
+	for i := range l {
+		movies = append(movies, &l[i])
+	}
+
+	return movies
+}
+
 type Movie struct {
 	ID             int64  `json:"id" swaggerignore:"true"`
 	Name           string `json:"name"`
 	Description    string `json:"description"`
 	ProductionYear int    `json:"production_year"`
 	Poster         string `json:"poster"`
-	Actors         string `json:"actors"`
-	Genre          string `json:"genre"`
+	// Rating is the average of the votes, 0 for a movie without votes.
+	Rating      float64 `json:"rating"`
+	RatingCount int     `json:"rating_count"`
+	// RatingScore is the Bayesian-weighted average the listing is sorted by.
+	RatingScore float64 `json:"rating_score"`
+	// UserRating is the vote of the caller, null if they haven't rated the movie.
+	UserRating *int `json:"user_rating"`
+	// Genres, Cast and Crew are stored apart from the movie and loaded separately.
+	Genres []Genre      `json:"genres"`
+	Cast   []CastMember `json:"cast"`
+	Crew   []CrewMember `json:"crew"`
+}
+
+// MovieInput is the body of the movie create and update requests.
+type MovieInput struct {
+	Name           string `json:"name" validate:"required,max=255"`
+	Description    string `json:"description"`
+	ProductionYear int    `json:"production_year" validate:"required,production_year"`
+	Poster         string `json:"poster" validate:"omitempty,url,max=255"`
+	// Genres lists the slugs of the genres.
+	Genres []string `json:"genres" validate:"required,min=1,max=5,unique,dive,max=50,slug"`
+}
+
+// Validate reports the invalid fields keyed by their JSON names.
+func (i MovieInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// Changes returns the fields that differ in the other input, keyed by their JSON names.
+func (i MovieInput) Changes(other MovieInput) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if i.Name != other.Name {
+		changes["name"] = other.Name
+	}
+	if i.Description != other.Description {
+		changes["description"] = other.Description
+	}
+	if i.ProductionYear != other.ProductionYear {
+		changes["production_year"] = other.ProductionYear
+	}
+	if i.Poster != other.Poster {
+		changes["poster"] = other.Poster
+	}
+	if !sameSlugs(i.Genres, other.Genres) {
+		changes["genres"] = other.Genres
+	}
+
+	return changes
+}
+
+func (i MovieInput) Movie() Movie {
+	return Movie{
+		Name:           i.Name,
+		Description:    i.Description,
+		ProductionYear: i.ProductionYear,
+		Poster:         i.Poster,
+	}
+}
+
+// sameSlugs reports whether both lists have the same slugs in any order.
+func sameSlugs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[string]int, len(a))
+	for _, slug := range a {
+		seen[slug]++
+	}
+	for _, slug := range b {
+		if seen[slug] == 0 {
+			return false
+		}
+		seen[slug]--
+	}
+
+	return true
+}
+
+// MovieDuplicateCheckInput describes a movie an editor is about to create.
+type MovieDuplicateCheckInput struct {
+	Name           string `json:"name" validate:"required,max=255"`
+	ProductionYear int    `json:"production_year" validate:"required,production_year"`
+}
+
+func (i MovieDuplicateCheckInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// MovieDuplicate is an existing movie of the same year with a similar name. Similarity goes
+// from 0 to 1, names that differ only in case and diacritics have the similarity of 1.
+type MovieDuplicate struct {
+	Movie
+	Similarity float64 `json:"similarity"`
+}
+
+// latestProductionYear is the last year a movie can be produced in as of now.
+func latestProductionYear() int {
+	return time.Now().Year() + maxYearsAhead
+}
+
+func validateProductionYear(fl validator.FieldLevel) bool {
+	year := fl.Field().Int()
+
+	return year >= FirstProductionYear && year <= int64(latestProductionYear())
+}
+
+// Input returns the fields of the movie that can be changed.
+func (m Movie) Input() MovieInput {
+	genres := make([]string, 0, len(m.Genres))
+	for _, genre := range m.Genres {
+		genres = append(genres, genre.Slug)
+	}
+
+	return MovieInput{
+		Name:           m.Name,
+		Description:    m.Description,
+		ProductionYear: m.ProductionYear,
+		Poster:         m.Poster,
+		Genres:         genres,
+	}
+}
+
+// PatchFormat is the format of a partial update document.
+type PatchFormat int
+
+const (
+	// MergePatch documents (RFC 7396) list the fields to change, null removes a field.
+	MergePatch PatchFormat = iota + 1
+	// JSONPatch documents (RFC 6902) list the operations to apply.
+	JSONPatch
+)
+
+// MoviePatch is a partial update of the fields of MovieInput.
+type MoviePatch struct {
+	Format   PatchFormat
+	Document []byte
+}
+
+// ListMoviesInput describes paging, sorting and filtering of the movie listing.
+type ListMoviesInput struct {
+	Page     int      `form:"page" validate:"gte=0"`
+	Limit    int      `form:"limit" validate:"gte=0,lte=100"`
+	SortBy   string   `form:"sort_by" validate:"omitempty,oneof=id name production_year rating"`
+	Order    string   `form:"order" validate:"omitempty,oneof=asc desc"`
+	Genres   []string `form:"genre" validate:"max=5,dive,max=50"`
+	YearFrom int      `form:"year_from" validate:"gte=0"`
+	YearTo   int      `form:"year_to" validate:"omitempty,gtefield=YearFrom"`
+	Name     string   `form:"name" validate:"omitempty,max=255"`
+	Paging   string   `form:"paging" validate:"omitempty,oneof=offset cursor"`
+	Cursor   string   `form:"cursor"`
+
+	// the crew filters take person ids
+	Director        int64 `form:"director" validate:"gte=0"`
+	Writer          int64 `form:"writer" validate:"gte=0"`
+	Composer        int64 `form:"composer" validate:"gte=0"`
+	Cinematographer int64 `form:"cinematographer" validate:"gte=0"`
+}
+
+func (i ListMoviesInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+// WithDefaults fills in the paging and sorting values the client left out.
+func (i ListMoviesInput) WithDefaults() ListMoviesInput {
+	if i.Page == 0 {
+		i.Page = 1
+	}
+	if i.Limit == 0 {
+		i.Limit = DefaultMoviesLimit
+	}
+	if i.SortBy == "" {
+		i.SortBy = "id"
+	}
+	if i.Order == "" {
+		i.Order = "asc"
+	}
+	i.Genres = normalizeSlugs(i.Genres)
+
+	return i
+}
+
+// normalizeSlugs splits comma-separated slugs and drops the case and the repeated ones,
+// so both ?genre=crime&genre=drama and ?genre=Crime,Drama select the same genres.
+func normalizeSlugs(slugs []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, s := range slugs {
+		for _, slug := range strings.Split(s, ",") {
+			slug = strings.ToLower(strings.TrimSpace(slug))
+			if slug == "" || seen[slug] {
+				continue
+			}
+
+			seen[slug] = true
+			normalized = append(normalized, slug)
+		}
+	}
+
+	return normalized
+}
+
+// CrewFilters returns the crew filters the client has set.
+func (i ListMoviesInput) CrewFilters() []CrewFilter {
+	var filters []CrewFilter
+	for _, f := range []CrewFilter{
+		{Job: JobDirector, PersonID: i.Director},
+		{Job: JobWriter, PersonID: i.Writer},
+		{Job: JobComposer, PersonID: i.Composer},
+		{Job: JobCinematographer, PersonID: i.Cinematographer},
+	} {
+		if f.PersonID > 0 {
+			filters = append(filters, f)
+		}
+	}
+
+	return filters
+}
+
+// UsesCursor reports whether the listing is paged by keyset cursor instead of page number.
+func (i ListMoviesInput) UsesCursor() bool {
+	return i.Paging == "cursor" || i.Cursor != ""
+}
+
+func (i ListMoviesInput) Offset() int {
+	if i.Page < 1 {
+		return 0
+	}
+
+	return (i.Page - 1) * i.Limit
+}
+
+// MovieCursor points at the last movie of a keyset page: the value of the
+// sort column and the id that breaks ties between equal values.
+type MovieCursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	Key    string `json:"k"`
+	ID     int64  `json:"i"`
+}
+
+func NewMovieCursor(movie Movie, sortBy, order string) MovieCursor {
+	var key string
+	switch sortBy {
+	case "name":
+		key = movie.Name
+	case "production_year":
+		key = strconv.Itoa(movie.ProductionYear)
+	case "rating":
+		key = strconv.FormatFloat(movie.RatingScore, 'g', -1, 64)
+	default:
+		key = strconv.FormatInt(movie.ID, 10)
+	}
+
+	return MovieCursor{SortBy: sortBy, Order: order, Key: key, ID: movie.ID}
+}
+
+type MovieSearchInput struct {
+	Query string `form:"q" validate:"required,max=255"`
+	Page  int    `form:"page" validate:"gte=0"`
+	Limit int    `form:"limit" validate:"gte=0,lte=100"`
+}
+
+func (i MovieSearchInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+func (i MovieSearchInput) WithDefaults() MovieSearchInput {
+	if i.Page == 0 {
+		i.Page = 1
+	}
+	if i.Limit == 0 {
+		i.Limit = DefaultMoviesLimit
+	}
+
+	return i
+}
+
+func (i MovieSearchInput) Offset() int {
+	if i.Page < 1 {
+		return 0
+	}
+
+	return (i.Page - 1) * i.Limit
+}
+
+// MovieSearchResult is a movie matched by the search query. Snippet is an HTML-escaped fragment
+// of the matched text with the matches wrapped in <mark> tags.
+type MovieSearchResult struct {
+	Movie
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
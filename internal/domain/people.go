@@ -0,0 +1,88 @@
+package domain
+
+import "time"
+
+var (
+	ErrPersonNotFound      = NewNotFoundError("person_not_found", "person not found")
+	ErrDuplicateCastMember = NewConflictError("duplicate_cast_member", "the person plays the character in the movie already")
+)
+
+type Person struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PersonInput struct {
+	Name string `json:"name" validate:"required,max=255"`
+	Bio  string `json:"bio" validate:"max=10000"`
+}
+
+func (i PersonInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// ListPeopleInput describes paging of the people listing, optionally filtered by a part of the name.
+type ListPeopleInput struct {
+	Name  string `form:"name" validate:"omitempty,max=255"`
+	Page  int    `form:"page" validate:"gte=0"`
+	Limit int    `form:"limit" validate:"gte=0,lte=100"`
+}
+
+func (i ListPeopleInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+func (i ListPeopleInput) WithDefaults() ListPeopleInput {
+	if i.Page == 0 {
+		i.Page = 1
+	}
+	if i.Limit == 0 {
+		i.Limit = DefaultMoviesLimit
+	}
+
+	return i
+}
+
+func (i ListPeopleInput) Offset() int {
+	if i.Page < 1 {
+		return 0
+	}
+
+	return (i.Page - 1) * i.Limit
+}
+
+// CastMember is a person playing in a movie. Billing is the position in the credits, starting from 1.
+type CastMember struct {
+	PersonID  int64  `json:"person_id"`
+	Name      string `json:"name"`
+	Character string `json:"character,omitempty"`
+	Billing   int    `json:"billing"`
+}
+
+type CastMemberInput struct {
+	PersonID  int64  `json:"person_id" validate:"required,gt=0"`
+	Character string `json:"character" validate:"max=255"`
+}
+
+// SetCastInput lists the whole cast of a movie in billing order, up to 200 people.
+type SetCastInput struct {
+	Cast []CastMemberInput `json:"cast" validate:"max=200,dive"`
+}
+
+func (i SetCastInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// PersonMovie is a credit in the filmography of a person: a role in the cast,
+// with the character and billing, or a job in the crew, with the department.
+type PersonMovie struct {
+	MovieID        int64      `json:"movie_id"`
+	Name           string     `json:"name"`
+	ProductionYear int        `json:"production_year"`
+	Character      string     `json:"character,omitempty"`
+	Billing        int        `json:"billing,omitempty"`
+	Department     Department `json:"department,omitempty"`
+	Job            string     `json:"job,omitempty"`
+}
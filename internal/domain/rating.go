@@ -0,0 +1,12 @@
+package domain
+
+var ErrRatingNotFound = NewNotFoundError("rating_not_found", "the movie hasn't been rated by the user")
+
+// RatingInput is the vote of a user for a movie.
+type RatingInput struct {
+	Rating int `json:"rating" validate:"required,min=1,max=10"`
+}
+
+func (i RatingInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
@@ -0,0 +1,119 @@
+package domain
+
+import "time"
+
+var (
+	ErrReviewNotFound     = NewNotFoundError("review_not_found", "review not found")
+	ErrReviewExists       = NewConflictError("review_exists", "the user has reviewed the movie already")
+	ErrNotReviewAuthor    = NewForbiddenError("not_review_author", "only the author can change the review")
+	ErrOwnReviewVote      = NewForbiddenError("own_review_vote", "authors can't vote for their own reviews")
+	ErrReviewVoteNotFound = NewNotFoundError("review_vote_not_found", "the user hasn't voted for the review")
+	ErrOwnReviewModerated = NewForbiddenError("own_review_moderation", "editors can't moderate their own reviews")
+	ErrReviewChanged      = NewConflictError("review_changed", "the review has been edited since it was read")
+)
+
+// ReviewStatus is the moderation state of a review. New and edited reviews are pending
+// until an editor approves or rejects them, only approved reviews are public.
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+type Review struct {
+	ID      int64        `json:"id"`
+	MovieID int64        `json:"movie_id"`
+	Author  ReviewAuthor `json:"author"`
+	Title   string       `json:"title"`
+	Body    string       `json:"body"`
+	Status  ReviewStatus `json:"status"`
+	// ModerationNote explains the rejection to the author, only the author and the editors see it.
+	ModerationNote string    `json:"moderation_note,omitempty"`
+	HelpfulCount   int       `json:"helpful_count"`
+	UnhelpfulCount int       `json:"unhelpful_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ReviewAuthor struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type ReviewInput struct {
+	Title string `json:"title" validate:"max=200"`
+	Body  string `json:"body" validate:"required,min=20,max=10000"`
+}
+
+func (i ReviewInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// ModerateReviewInput is the decision of an editor, the note is shown to the author.
+// UpdatedAt is the updated_at of the review the editor has read, the decision doesn't
+// apply to a text the author has changed since.
+type ModerateReviewInput struct {
+	Status    ReviewStatus `json:"status" validate:"required,oneof=approved rejected"`
+	Note      string       `json:"note" validate:"max=1000"`
+	UpdatedAt time.Time    `json:"updated_at" validate:"required"`
+}
+
+func (i ModerateReviewInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// ReviewVoteInput tells whether the review was helpful to the voter.
+type ReviewVoteInput struct {
+	Helpful *bool `json:"helpful" validate:"required"`
+}
+
+func (i ReviewVoteInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+// ListReviewsInput describes paging of the review listings. The status filter
+// is honoured only where the caller may see reviews that aren't approved.
+type ListReviewsInput struct {
+	Status ReviewStatus `form:"status" validate:"omitempty,oneof=pending approved rejected"`
+	Page   int          `form:"page" validate:"gte=0"`
+	Limit  int          `form:"limit" validate:"gte=0,lte=100"`
+}
+
+func (i ListReviewsInput) Validate() error {
+	return fieldValidationError(validate.Struct(i))
+}
+
+func (i ListReviewsInput) WithDefaults() ListReviewsInput {
+	if i.Page == 0 {
+		i.Page = 1
+	}
+	if i.Limit == 0 {
+		i.Limit = DefaultMoviesLimit
+	}
+
+	return i
+}
+
+func (i ListReviewsInput) Offset() int {
+	if i.Page < 1 {
+		return 0
+	}
+
+	return (i.Page - 1) * i.Limit
+}
+
+// ReviewFilter selects the reviews of a listing, zero fields don't filter.
+type ReviewFilter struct {
+	MovieID int64
+	UserID  int64
+	Status  ReviewStatus
+	// OldestFirst orders the moderation queue, the other listings show the newest reviews first.
+	OldestFirst bool
+}
+
+// CanModerate reports whether the caller may moderate reviews.
+func (p Principal) CanModerate() bool {
+	return (p.Role == RoleEditor || p.Role == RoleAdmin) && p.TwoFactor
+}
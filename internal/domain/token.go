@@ -2,9 +2,87 @@ package domain
 
 import "time"
 
+var (
+	ErrInvalidAccessToken  = NewUnauthorizedError("invalid_access_token", "invalid or expired access token")
+	ErrTokenRevoked        = NewUnauthorizedError("token_revoked", "token revoked")
+	ErrInvalidRefreshToken = NewUnauthorizedError("invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenExpired = NewUnauthorizedError("refresh_token_expired", "refresh token expired")
+	ErrRefreshTokenReused  = NewUnauthorizedError("refresh_token_reused", "refresh token reused")
+	ErrSessionNotFound     = NewNotFoundError("session_not_found", "session not found")
+	ErrInsufficientRole    = NewForbiddenError("insufficient_role", "not enough permissions")
+	ErrInsufficientScope   = NewForbiddenError("insufficient_scope", "the api key lacks the scope")
+	ErrSessionRequired     = NewForbiddenError("session_required", "not allowed with an api key")
+	ErrTwoFactorRequired   = NewForbiddenError("two_factor_required", "the role requires two-factor authentication, enroll at /me/2fa/enroll")
+)
+
+// RefreshSession is a single refresh token. The tokens issued by rotating one another,
+// starting from a sign-in, belong to the same family. The family is what users see as a session.
 type RefreshSession struct {
+	ID         int64
+	UserID     int64
+	Token      string
+	FamilyID   string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RotatedAt  *time.Time
+}
+
+// PasswordResetToken is an emailed one-time token that allows to set a new password.
+// Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
 	ID        int64
 	UserID    int64
-	Token     string
+	TokenHash string
+	CreatedAt time.Time
 	ExpiresAt time.Time
 }
+
+// ClientInfo describes the client a session is used from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Session is a signed-in device of the user.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// Principal is the caller authenticated by an access token or an API key.
+// Callers with an access token have no scopes and are limited by the role only.
+// TwoFactor is set for the editors and admins who have two-factor authentication enabled.
+type Principal struct {
+	UserID    int64
+	Role      Role
+	TwoFactor bool
+	TokenID   string
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	APIKeyID  int64
+	Scopes    []string
+}
+
+// HasScope reports whether the caller may act within the scope.
+func (p Principal) HasScope(scope string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
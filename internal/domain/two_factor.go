@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+var (
+	ErrTwoFactorNotEnrolled = NewConflictError("two_factor_not_enrolled", "two-factor authentication is not enrolled")
+	ErrTwoFactorEnabled     = NewConflictError("two_factor_enabled", "two-factor authentication is already enabled")
+	ErrInvalidCode          = NewValidationError("invalid_code", "invalid code", nil)
+)
+
+// TOTP is the authenticator app secret of the user. It is enabled once the user confirms
+// the enrollment with a valid code. LastStep is the time step of the last accepted code,
+// a code is never accepted twice.
+type TOTP struct {
+	UserID    int64
+	Secret    string
+	EnabledAt *time.Time
+	LastStep  int64
+}
+
+func (t TOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorEnrollment is the secret to add to an authenticator app.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are shown once when two-factor authentication is enabled. Each of them
+// can be used once instead of a code from the authenticator app.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// SignInResult holds either the token pair or, when the user has two-factor authentication
+// enabled, the challenge token to complete the sign-in with.
+type SignInResult struct {
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+func (i TwoFactorCodeInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+func (i DisableTwoFactorInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+// SignInMFAInput completes the sign-in with the code from the authenticator app or a recovery code.
+type SignInMFAInput struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+func (i SignInMFAInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
@@ -1,7 +1,6 @@
 package domain
 
 import (
-	"errors"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -11,16 +10,46 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
+	if err := validate.RegisterValidation("production_year", validateProductionYear); err != nil {
+		panic(err)
+	}
+	if err := validate.RegisterValidation("slug", validateSlug); err != nil {
+		panic(err)
+	}
 }
 
-var ErrUserNotFound = errors.New("user with such credentials not found")
+var (
+	ErrInvalidCredentials = NewUnauthorizedError("invalid_credentials", "wrong email or password")
+	ErrAccountNotFound    = NewNotFoundError("account_not_found", "user not found")
+	ErrEmailTaken         = NewConflictError("email_taken", "user with such email already exists")
+	ErrWrongPassword      = NewForbiddenError("wrong_password", "wrong password")
+	ErrEmailNotVerified   = NewForbiddenError("email_not_verified", "email is not verified")
+	ErrInvalidToken       = NewValidationError("invalid_token", "invalid or expired token", nil)
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// RequiresTwoFactor reports whether the role is only granted with two-factor authentication enabled.
+// Editors and admins can change and delete the catalogue.
+func (r Role) RequiresTwoFactor() bool {
+	return r == RoleEditor || r == RoleAdmin
+}
 
 type User struct {
-	ID           int64     `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	Password     string    `json:"password"`
-	RegisteredAt time.Time `json:"registered_at"`
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	Password     string     `json:"-"`
+	Role         Role       `json:"role"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	VerifiedAt   *time.Time `json:"verified_at"`
 }
 
 type SignUpInput struct {
@@ -30,7 +59,7 @@ type SignUpInput struct {
 }
 
 func (i SignUpInput) Validate() error {
-	return validate.Struct(i)
+	return validationError(validate.Struct(i))
 }
 
 type SignInInput struct {
@@ -39,5 +68,57 @@ type SignInInput struct {
 }
 
 func (i SignInInput) Validate() error {
-	return validate.Struct(i)
+	return validationError(validate.Struct(i))
+}
+
+// UpdateProfileInput changes the fields that are set and leaves the rest as they are.
+type UpdateProfileInput struct {
+	Name  *string `json:"name" validate:"omitempty,gte=2"`
+	Email *string `json:"email" validate:"omitempty,email"`
+}
+
+func (i UpdateProfileInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,gte=6"`
+}
+
+func (i ChangePasswordInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+func (i ForgotPasswordInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,gte=6"`
+}
+
+func (i ResetPasswordInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (i VerifyEmailInput) Validate() error {
+	return validationError(validate.Struct(i))
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (i ResendVerificationInput) Validate() error {
+	return validationError(validate.Struct(i))
 }
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at"
+
+type APIKeys struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeys(db *sqlx.DB) *APIKeys {
+	return &APIKeys{db: db}
+}
+
+func (r APIKeys) Create(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+apiKeyColumns,
+		key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt)
+
+	k, err := scanAPIKey(row)
+	if isUniqueViolation(err) {
+		return domain.APIKey{}, domain.ErrAPIKeyNameTaken
+	}
+
+	return k, err
+}
+
+func (r APIKeys) ListByUser(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id=$1 ORDER BY created_at DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetByHash returns the key with the given hash, domain.ErrInvalidAPIKey if there is none.
+func (r APIKeys) GetByHash(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash=$1", keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+
+	return k, err
+}
+
+// Touch records the use of the key. The time is updated at most once a minute,
+// so busy clients don't cause a write on every request.
+func (r APIKeys) Touch(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at=now()
+		WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, id)
+
+	return err
+}
+
+// Delete removes the key of the user. It reports false if the user has no such key.
+func (r APIKeys) Delete(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM api_keys WHERE user_id=$1 AND id=$2", userID, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row scanner) (domain.APIKey, error) {
+	var k domain.APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&k.Scopes), &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt)
+
+	return k, err
+}
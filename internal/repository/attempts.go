@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// LoginAttempts keeps the failed sign-in counters in Postgres, so they are shared by all the instances.
+type LoginAttempts struct {
+	db *sqlx.DB
+}
+
+func NewLoginAttempts(db *sqlx.DB) *LoginAttempts {
+	return &LoginAttempts{db: db}
+}
+
+// Get returns the counter of the key, a zero counter if there were no recent failures.
+func (r LoginAttempts) Get(ctx context.Context, key string) (domain.LoginAttempts, error) {
+	a := domain.LoginAttempts{Key: key}
+	err := r.db.QueryRowContext(ctx, "SELECT failures, last_failed_at, locked_until FROM login_attempts WHERE key=$1", key).
+		Scan(&a.Failures, &a.LastFailedAt, &a.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, nil
+	}
+
+	return a, err
+}
+
+// Fail counts a failed attempt. The counter starts over when there were no failures
+// and no lockout within the window.
+func (r LoginAttempts) Fail(ctx context.Context, key string, window time.Duration) (domain.LoginAttempts, error) {
+	a := domain.LoginAttempts{Key: key}
+	err := r.db.QueryRowContext(ctx, `INSERT INTO login_attempts (key, failures, last_failed_at) VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN GREATEST(login_attempts.last_failed_at, login_attempts.locked_until) < now() - make_interval(secs => $2)
+				THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN GREATEST(login_attempts.last_failed_at, login_attempts.locked_until) < now() - make_interval(secs => $2)
+				THEN NULL ELSE login_attempts.locked_until END,
+			last_failed_at = now()
+		RETURNING failures, last_failed_at, locked_until`, key, window.Seconds()).
+		Scan(&a.Failures, &a.LastFailedAt, &a.LockedUntil)
+
+	return a, err
+}
+
+func (r LoginAttempts) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE login_attempts SET locked_until=$2 WHERE key=$1", key, until)
+
+	return err
+}
+
+func (r LoginAttempts) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key=$1", key)
+
+	return err
+}
+
+// UnlockExpired clears the lockouts that have ended for the keys with the prefix and returns the unlocked keys.
+// The counter window runs from the end of the lockout, as it did while the key was locked.
+func (r LoginAttempts) UnlockExpired(ctx context.Context, prefix string) ([]domain.LoginAttempts, error) {
+	rows, err := r.db.QueryContext(ctx, `UPDATE login_attempts
+		SET locked_until = NULL, last_failed_at = GREATEST(last_failed_at, locked_until)
+		WHERE starts_with(key, $1) AND locked_until <= now()
+		RETURNING key, failures, last_failed_at`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.LoginAttempts
+	for rows.Next() {
+		var a domain.LoginAttempts
+		if err := rows.Scan(&a.Key, &a.Failures, &a.LastFailedAt); err != nil {
+			return nil, err
+		}
+
+		list = append(list, a)
+	}
+
+	return list, rows.Err()
+}
+
+// DeleteExpired removes the counters of the keys with the prefix that had no failures and no lockout within the window.
+func (r LoginAttempts) DeleteExpired(ctx context.Context, prefix string, window time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts
+		WHERE starts_with(key, $1) AND GREATEST(last_failed_at, locked_until) < now() - make_interval(secs => $2)`, prefix, window.Seconds())
+
+	return err
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// MemoryLoginAttempts keeps the failed sign-in counters in memory. The counters are
+// lost on restart and not shared between instances, so it fits a single instance only.
+type MemoryLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func NewMemoryLoginAttempts() *MemoryLoginAttempts {
+	return &MemoryLoginAttempts{attempts: make(map[string]domain.LoginAttempts)}
+}
+
+func (r *MemoryLoginAttempts) Get(_ context.Context, key string) (domain.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[key]
+	if !ok {
+		return domain.LoginAttempts{Key: key}, nil
+	}
+
+	return a, nil
+}
+
+func (r *MemoryLoginAttempts) Fail(_ context.Context, key string, window time.Duration) (domain.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	a, ok := r.attempts[key]
+	if !ok || expired(a, now.Add(-window)) {
+		a = domain.LoginAttempts{Key: key}
+	}
+
+	a.Failures++
+	a.LastFailedAt = now
+	r.attempts[key] = a
+
+	return a, nil
+}
+
+func (r *MemoryLoginAttempts) Lock(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a, ok := r.attempts[key]; ok {
+		a.LockedUntil = &until
+		r.attempts[key] = a
+	}
+
+	return nil
+}
+
+func (r *MemoryLoginAttempts) Reset(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+
+	return nil
+}
+
+func (r *MemoryLoginAttempts) UnlockExpired(_ context.Context, prefix string) ([]domain.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	var list []domain.LoginAttempts
+	for k, a := range r.attempts {
+		if !strings.HasPrefix(k, prefix) || a.LockedUntil == nil || a.LockedUntil.After(now) {
+			continue
+		}
+
+		if a.LockedUntil.After(a.LastFailedAt) {
+			a.LastFailedAt = *a.LockedUntil
+		}
+		a.LockedUntil = nil
+		r.attempts[k] = a
+
+		list = append(list, a)
+	}
+
+	return list, nil
+}
+
+func (r *MemoryLoginAttempts) DeleteExpired(_ context.Context, prefix string, window time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := time.Now().Add(-window)
+	for k, a := range r.attempts {
+		if strings.HasPrefix(k, prefix) && expired(a, before) {
+			delete(r.attempts, k)
+		}
+	}
+
+	return nil
+}
+
+// expired reports whether the last failure and the lockout both ended before the given time.
+func expired(a domain.LoginAttempts, before time.Time) bool {
+	return a.LastFailedAt.Before(before) && (a.LockedUntil == nil || a.LockedUntil.Before(before))
+}
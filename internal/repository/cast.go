@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type Cast struct {
+	db *sqlx.DB
+}
+
+func NewCast(db *sqlx.DB) *Cast {
+	return &Cast{db: db}
+}
+
+// ListByMovies returns the casts of the movies in billing order, keyed by the movie id.
+func (r Cast) ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.CastMember, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT c.movie_id, c.person_id, p.name, c.character_name, c.billing
+		FROM movie_cast c JOIN people p ON p.id = c.person_id
+		WHERE c.movie_id = ANY($1)
+		ORDER BY c.movie_id, c.billing`, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	casts := make(map[int64][]domain.CastMember, len(movieIDs))
+	for rows.Next() {
+		var (
+			movieID int64
+			m       domain.CastMember
+		)
+		if err := rows.Scan(&movieID, &m.PersonID, &m.Name, &m.Character, &m.Billing); err != nil {
+			return nil, err
+		}
+
+		casts[movieID] = append(casts[movieID], m)
+	}
+
+	return casts, rows.Err()
+}
+
+// Set replaces the cast of the movie, the billing follows the order of the members.
+func (r Cast) Set(ctx context.Context, movieID int64, cast []domain.CastMemberInput) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the lock keeps concurrent replacements of the same cast from interleaving
+	var id int64
+	if err := tx.GetContext(ctx, &id, "SELECT id FROM movie WHERE id=$1 FOR UPDATE", movieID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrMovieNotFound
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movie_cast WHERE movie_id=$1", movieID); err != nil {
+		return err
+	}
+
+	for i, m := range cast {
+		_, err := tx.ExecContext(ctx, "INSERT INTO movie_cast (movie_id, person_id, character_name, billing) VALUES ($1, $2, $3, $4)",
+			movieID, m.PersonID, m.Character, i+1)
+		// the movie is locked, so the missing row can only be the person
+		if isForeignKeyViolation(err) {
+			return domain.ErrPersonNotFound
+		}
+		if isUniqueViolation(err) {
+			return domain.ErrDuplicateCastMember
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type Crew struct {
+	db *sqlx.DB
+}
+
+func NewCrew(db *sqlx.DB) *Crew {
+	return &Crew{db: db}
+}
+
+// ListByMovies returns the crews of the movies in the stored order, keyed by the movie id.
+func (r Crew) ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.CrewMember, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT c.movie_id, c.person_id, p.name, c.department, c.job
+		FROM movie_crew c JOIN people p ON p.id = c.person_id
+		WHERE c.movie_id = ANY($1)
+		ORDER BY c.movie_id, c.position`, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	crews := make(map[int64][]domain.CrewMember, len(movieIDs))
+	for rows.Next() {
+		var (
+			movieID int64
+			m       domain.CrewMember
+		)
+		if err := rows.Scan(&movieID, &m.PersonID, &m.Name, &m.Department, &m.Job); err != nil {
+			return nil, err
+		}
+
+		crews[movieID] = append(crews[movieID], m)
+	}
+
+	return crews, rows.Err()
+}
+
+// Set replaces the crew of the movie, keeping the order of the members.
+func (r Crew) Set(ctx context.Context, movieID int64, crew []domain.CrewMemberInput) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the lock keeps concurrent replacements of the same crew from interleaving
+	var id int64
+	if err := tx.GetContext(ctx, &id, "SELECT id FROM movie WHERE id=$1 FOR UPDATE", movieID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrMovieNotFound
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movie_crew WHERE movie_id=$1", movieID); err != nil {
+		return err
+	}
+
+	for i, m := range crew {
+		_, err := tx.ExecContext(ctx, "INSERT INTO movie_crew (movie_id, person_id, department, job, position) VALUES ($1, $2, $3, $4, $5)",
+			movieID, m.PersonID, m.Department, m.Job, i+1)
+		// the movie is locked, so the missing row can only be the person
+		if isForeignKeyViolation(err) {
+			return domain.ErrPersonNotFound
+		}
+		if isUniqueViolation(err) {
+			return domain.ErrDuplicateCrewMember
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+const foreignKeyViolation = "23503"
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation
+}
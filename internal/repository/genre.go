@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+const genreColumns = "id, slug, name"
+
+type Genres struct {
+	db *sqlx.DB
+}
+
+func NewGenres(db *sqlx.DB) *Genres {
+	return &Genres{db: db}
+}
+
+// List returns the whole taxonomy ordered by name.
+func (r Genres) List(ctx context.Context) ([]domain.Genre, error) {
+	return selectGenres(ctx, r.db, "SELECT "+genreColumns+" FROM genres ORDER BY name")
+}
+
+// Get returns the genre, domain.ErrGenreNotFound if there is none.
+func (r Genres) Get(ctx context.Context, slug string) (domain.Genre, error) {
+	g, err := scanGenre(r.db.QueryRowContext(ctx, "SELECT "+genreColumns+" FROM genres WHERE slug=$1", slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Genre{}, domain.ErrGenreNotFound
+	}
+
+	return g, err
+}
+
+// GetBySlugs returns the genres with the slugs, the missing slugs are skipped.
+func (r Genres) GetBySlugs(ctx context.Context, slugs []string) ([]domain.Genre, error) {
+	return selectGenres(ctx, r.db, "SELECT "+genreColumns+" FROM genres WHERE slug = ANY($1) ORDER BY name", pq.Array(slugs))
+}
+
+// ListByMovies returns the genres of the movies ordered by name, keyed by the movie id.
+func (r Genres) ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.Genre, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT mg.movie_id, g.id, g.slug, g.name
+		FROM movie_genres mg JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.movie_id = ANY($1)
+		ORDER BY mg.movie_id, g.name`, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := make(map[int64][]domain.Genre, len(movieIDs))
+	for rows.Next() {
+		var (
+			movieID int64
+			g       domain.Genre
+		)
+		if err := rows.Scan(&movieID, &g.ID, &g.Slug, &g.Name); err != nil {
+			return nil, err
+		}
+
+		genres[movieID] = append(genres[movieID], g)
+	}
+
+	return genres, rows.Err()
+}
+
+func (r Genres) Create(ctx context.Context, inp domain.GenreInput) (domain.Genre, error) {
+	g, err := scanGenre(r.db.QueryRowContext(ctx, "INSERT INTO genres (slug, name) VALUES ($1, $2) RETURNING "+genreColumns, inp.Slug, inp.Name))
+	if isUniqueViolation(err) {
+		return domain.Genre{}, domain.ErrGenreTaken
+	}
+
+	return g, err
+}
+
+// Update renames the genre, the slug can be changed too.
+func (r Genres) Update(ctx context.Context, slug string, inp domain.GenreInput) (domain.Genre, error) {
+	g, err := scanGenre(r.db.QueryRowContext(ctx, "UPDATE genres SET slug=$1, name=$2 WHERE slug=$3 RETURNING "+genreColumns, inp.Slug, inp.Name, slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Genre{}, domain.ErrGenreNotFound
+	}
+	if isUniqueViolation(err) {
+		return domain.Genre{}, domain.ErrGenreTaken
+	}
+
+	return g, err
+}
+
+// Delete removes a genre no movie uses.
+func (r Genres) Delete(ctx context.Context, slug string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM genres WHERE slug=$1", slug)
+	if isForeignKeyViolation(err) {
+		return domain.ErrGenreInUse
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrGenreNotFound
+	}
+
+	return nil
+}
+
+// Merge moves the movies of the genre to the other one and removes the genre.
+func (r Genres) Merge(ctx context.Context, slug, into string) (domain.Genre, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Genre{}, err
+	}
+	defer tx.Rollback()
+
+	// both genres are locked in the same order, so opposite merges can't deadlock
+	locked, err := selectGenres(ctx, tx, "SELECT "+genreColumns+" FROM genres WHERE slug = ANY($1) ORDER BY id FOR UPDATE", pq.Array([]string{slug, into}))
+	if err != nil {
+		return domain.Genre{}, err
+	}
+
+	var source, target *domain.Genre
+	for i := range locked {
+		switch locked[i].Slug {
+		case slug:
+			source = &locked[i]
+		case into:
+			target = &locked[i]
+		}
+	}
+
+	if source == nil || target == nil {
+		return domain.Genre{}, domain.ErrGenreNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO movie_genres (movie_id, genre_id)
+		SELECT movie_id, $2 FROM movie_genres WHERE genre_id=$1
+		ON CONFLICT DO NOTHING`, source.ID, target.ID); err != nil {
+		return domain.Genre{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movie_genres WHERE genre_id=$1", source.ID); err != nil {
+		return domain.Genre{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM genres WHERE id=$1", source.ID); err != nil {
+		return domain.Genre{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Genre{}, err
+	}
+
+	return *target, nil
+}
+
+func selectGenres(ctx context.Context, q sqlx.QueryerContext, query string, args ...interface{}) ([]domain.Genre, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := make([]domain.Genre, 0)
+	for rows.Next() {
+		g, err := scanGenre(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, g)
+	}
+
+	return genres, rows.Err()
+}
+
+func scanGenre(row scanner) (domain.Genre, error) {
+	var g domain.Genre
+	err := row.Scan(&g.ID, &g.Slug, &g.Name)
+
+	return g, err
+}
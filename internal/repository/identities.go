@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type Identities struct {
+	db *sqlx.DB
+}
+
+func NewIdentities(db *sqlx.DB) *Identities {
+	return &Identities{db: db}
+}
+
+// Get returns the identity of the provider's account, domain.ErrAccountNotLinked if it isn't linked.
+func (r Identities) Get(ctx context.Context, provider, subject string) (domain.Identity, error) {
+	var i domain.Identity
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE provider=$1 AND subject=$2", provider, subject).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Identity{}, domain.ErrAccountNotLinked
+	}
+
+	return i, err
+}
+
+// Create links the provider's account to the user, domain.ErrIdentityLinked if it is already linked.
+func (r Identities) Create(ctx context.Context, identity domain.Identity) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)",
+		identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if isUniqueViolation(err) {
+		return domain.ErrIdentityLinked
+	}
+
+	return err
+}
@@ -1,25 +1,63 @@
 package models
 
-import "github.com/lukinairina90/crud_movies/internal/domain"
+import (
+	"math"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
 
 type Movie struct {
-	ID             int64  `db:"id"`
-	Name           string `db:"name"`
-	Description    string `db:"description"`
-	ProductionYear int    `db:"production_year"`
-	Poster         string `db:"poster"`
-	Actors         string `db:"actors"`
-	Genre          string `db:"genre"`
+	ID             int64   `db:"id"`
+	Name           string  `db:"name"`
+	Description    string  `db:"description"`
+	ProductionYear int     `db:"production_year"`
+	Poster         string  `db:"poster"`
+	RatingCount    int     `db:"rating_count"`
+	RatingSum      int64   `db:"rating_sum"`
+	RatingScore    float64 `db:"rating_score"`
 }
 
 func (m Movie) ToDomain() domain.Movie {
+	var rating float64
+	if m.RatingCount > 0 {
+		rating = math.Round(float64(m.RatingSum)/float64(m.RatingCount)*100) / 100
+	}
+
 	return domain.Movie{
 		ID:             m.ID,
 		Name:           m.Name,
 		Description:    m.Description,
 		ProductionYear: m.ProductionYear,
 		Poster:         m.Poster,
-		Actors:         m.Actors,
-		Genre:          m.Genre,
+		Rating:         rating,
+		RatingCount:    m.RatingCount,
+		RatingScore:    m.RatingScore,
+	}
+}
+
+type MovieSearchResult struct {
+	Movie
+	CastNames string  `db:"cast_names"`
+	Rank      float64 `db:"rank"`
+	Snippet   string  `db:"snippet"`
+}
+
+func (m MovieSearchResult) ToDomain() domain.MovieSearchResult {
+	return domain.MovieSearchResult{
+		Movie:   m.Movie.ToDomain(),
+		Rank:    m.Rank,
+		Snippet: m.Snippet,
+	}
+}
+
+type MovieDuplicate struct {
+	Movie
+	Similarity float64 `db:"similarity"`
+}
+
+func (m MovieDuplicate) ToDomain() domain.MovieDuplicate {
+	return domain.MovieDuplicate{
+		Movie:      m.Movie.ToDomain(),
+		Similarity: m.Similarity,
 	}
 }
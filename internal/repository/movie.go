@@ -2,12 +2,22 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/lukinairina90/crud_movies/internal/domain"
 	"github.com/lukinairina90/crud_movies/internal/repository/models"
 )
 
+// movieColumns lists the columns scanned into models.Movie. The table has
+// more columns than the model (e.g. the search vector), so SELECT * is not used.
+const movieColumns = "id, name, description, production_year, poster, rating_count, rating_sum, rating_score"
+
 type Movie struct {
 	db *sqlx.DB
 }
@@ -16,9 +26,84 @@ func NewMovie(db *sqlx.DB) *Movie {
 	return &Movie{db: db}
 }
 
-func (m Movie) List(ctx context.Context) (domain.ListMovie, error) {
+// moviePatchColumns maps the JSON names of the fields Patch changes to their columns.
+var moviePatchColumns = map[string]string{
+	"name":            "name",
+	"description":     "description",
+	"production_year": "production_year",
+	"poster":          "poster",
+}
+
+var movieSortColumns = map[string]string{
+	"id":              "id",
+	"name":            "name",
+	"production_year": "production_year",
+	"rating":          "rating_score",
+}
+
+func (m Movie) List(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, error) {
+	where, args := movieFilter(inp)
+
+	var total int
+	if err := m.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM movie"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	column, order := movieOrder(inp)
+
+	query := fmt.Sprintf("SELECT %s FROM movie%s ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d", movieColumns, where, column, order, order, len(args)+1, len(args)+2)
+	args = append(args, inp.Limit, inp.Offset())
+
+	list, err := m.selectMovies(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+// ListAfter returns the page of movies that follows the cursor position in the
+// (sort column, id) order. A nil cursor starts from the beginning of the listing.
+// Unlike offsets, the position does not shift when movies are inserted or deleted.
+func (m Movie) ListAfter(ctx context.Context, inp domain.ListMoviesInput, cursor *domain.MovieCursor) (domain.ListMovie, int, error) {
+	where, args := movieFilter(inp)
+
+	var total int
+	if err := m.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM movie"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	column, order := movieOrder(inp)
+
+	if cursor != nil {
+		cmp := ">"
+		if order == "DESC" {
+			cmp = "<"
+		}
+
+		args = append(args, cursor.Key, cursor.ID)
+		cond := fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, cmp, len(args)-1, len(args))
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM movie%s ORDER BY %s %s, id %s LIMIT $%d", movieColumns, where, column, order, order, len(args)+1)
+	args = append(args, inp.Limit)
+
+	list, err := m.selectMovies(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+func (m Movie) selectMovies(ctx context.Context, query string, args ...interface{}) (domain.ListMovie, error) {
 	var list []models.Movie
-	if err := m.db.SelectContext(ctx, &list, "SELECT * FROM movie"); err != nil {
+	if err := m.db.SelectContext(ctx, &list, query, args...); err != nil {
 		return nil, err
 	}
 
@@ -30,53 +115,262 @@ func (m Movie) List(ctx context.Context) (domain.ListMovie, error) {
 	return dlist, nil
 }
 
+func movieOrder(inp domain.ListMoviesInput) (string, string) {
+	column, ok := movieSortColumns[inp.SortBy]
+	if !ok {
+		column = "id"
+	}
+
+	if inp.Order == "desc" {
+		return column, "DESC"
+	}
+
+	return column, "ASC"
+}
+
+// movieFilter builds the WHERE clause of the movie listing and its positional arguments.
+func movieFilter(inp domain.ListMoviesInput) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	// the movie has to have every one of the genres
+	if len(inp.Genres) > 0 {
+		args = append(args, pq.Array(inp.Genres), len(inp.Genres))
+		conds = append(conds, fmt.Sprintf(`id IN (SELECT mg.movie_id FROM movie_genres mg JOIN genres g ON g.id = mg.genre_id
+			WHERE g.slug = ANY($%d) GROUP BY mg.movie_id HAVING COUNT(*) = $%d)`, len(args)-1, len(args)))
+	}
+
+	for _, f := range inp.CrewFilters() {
+		args = append(args, f.PersonID, f.Job)
+		conds = append(conds, fmt.Sprintf("id IN (SELECT movie_id FROM movie_crew WHERE person_id = $%d AND lower(job) = lower($%d))", len(args)-1, len(args)))
+	}
+
+	if inp.YearFrom > 0 {
+		args = append(args, inp.YearFrom)
+		conds = append(conds, fmt.Sprintf("production_year >= $%d", len(args)))
+	}
+
+	if inp.YearTo > 0 {
+		args = append(args, inp.YearTo)
+		conds = append(conds, fmt.Sprintf("production_year <= $%d", len(args)))
+	}
+
+	if inp.Name != "" {
+		args = append(args, escapeLike(inp.Name)+"%")
+		conds = append(conds, fmt.Sprintf("name ILIKE $%d", len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
 func (m Movie) Get(ctx context.Context, id int) (domain.Movie, error) {
 	var movie models.Movie
-	if err := m.db.GetContext(ctx, &movie, "SELECT * FROM  movie WHERE id=$1", id); err != nil {
+	if err := m.db.GetContext(ctx, &movie, "SELECT "+movieColumns+" FROM movie WHERE id=$1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Movie{}, domain.ErrMovieNotFound
+		}
 		return domain.Movie{}, err
 	}
 
 	return movie.ToDomain(), nil
 }
 
+// Create stores the movie together with its genres.
 func (m Movie) Create(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
-	mMovie := models.Movie{
-		Name:           movie.Name,
-		Description:    movie.Description,
-		ProductionYear: movie.ProductionYear,
-		Poster:         movie.Poster,
-		Actors:         movie.Actors,
-		Genre:          movie.Genre,
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Movie{}, err
 	}
+	defer tx.Rollback()
 
-	if err := m.db.QueryRowxContext(ctx, "INSERT INTO movie (name, description, production_year, genre, actors, poster) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", mMovie.Name, mMovie.Description, mMovie.ProductionYear, mMovie.Genre, mMovie.Actors, mMovie.Poster).StructScan(&mMovie); err != nil {
+	var mMovie models.Movie
+	if err := tx.QueryRowxContext(ctx, "INSERT INTO movie (name, description, production_year, poster) VALUES ($1, $2, $3, $4) RETURNING "+movieColumns, movie.Name, movie.Description, movie.ProductionYear, movie.Poster).StructScan(&mMovie); err != nil {
+		if isUniqueViolation(err) {
+			return domain.Movie{}, m.duplicateError(ctx, movie.Name)
+		}
+		return domain.Movie{}, err
+	}
+
+	if err := setMovieGenres(ctx, tx, mMovie.ID, movie.Genres); err != nil {
+		return domain.Movie{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return domain.Movie{}, err
 	}
 
 	return mMovie.ToDomain(), nil
 }
 
+// Update replaces the movie together with its genres.
 func (m Movie) Update(ctx context.Context, id int, movie domain.Movie) (domain.Movie, error) {
-	mMovie := models.Movie{
-		Name:           movie.Name,
-		Description:    movie.Description,
-		ProductionYear: movie.ProductionYear,
-		Poster:         movie.Poster,
-		Actors:         movie.Actors,
-		Genre:          movie.Genre,
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+	defer tx.Rollback()
+
+	var mMovie models.Movie
+	if err := tx.QueryRowxContext(ctx, "UPDATE movie SET name=$1, description=$2, production_year=$3, poster=$4 WHERE id=$5 RETURNING "+movieColumns, movie.Name, movie.Description, movie.ProductionYear, movie.Poster, id).StructScan(&mMovie); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Movie{}, domain.ErrMovieNotFound
+		}
+		if isUniqueViolation(err) {
+			return domain.Movie{}, m.duplicateError(ctx, movie.Name)
+		}
+		return domain.Movie{}, err
+	}
+
+	if err := setMovieGenres(ctx, tx, mMovie.ID, movie.Genres); err != nil {
+		return domain.Movie{}, err
 	}
 
-	if err := m.db.QueryRowxContext(ctx, "UPDATE movie SET name=$1, description=$2, production_year=$3, genre=$4, actors=$5, poster=$6 WHERE id=$7 RETURNING *", mMovie.Name, mMovie.Description, mMovie.ProductionYear, mMovie.Genre, mMovie.Actors, mMovie.Poster, id).StructScan(&mMovie); err != nil {
+	if err := tx.Commit(); err != nil {
 		return domain.Movie{}, err
 	}
 
 	return mMovie.ToDomain(), nil
 }
 
+// Patch locks the movie and sets only the fields returned by patch, leaving the other columns as they are.
+// patch gets the movie with its genres as stored under the lock, so concurrent updates of the movie wait
+// until the patched one is written instead of being overwritten. The fields are keyed by their JSON names,
+// the "genres" field holds the []domain.Genre to set.
+func (m Movie) Patch(ctx context.Context, id int, patch func(domain.Movie) (map[string]interface{}, error)) (domain.Movie, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+	defer tx.Rollback()
+
+	var mMovie models.Movie
+	if err := tx.QueryRowxContext(ctx, "SELECT "+movieColumns+" FROM movie WHERE id=$1 FOR UPDATE", id).StructScan(&mMovie); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Movie{}, domain.ErrMovieNotFound
+		}
+		return domain.Movie{}, err
+	}
+
+	movie := mMovie.ToDomain()
+	if movie.Genres, err = selectGenres(ctx, tx, `SELECT g.id, g.slug, g.name
+		FROM movie_genres mg JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.movie_id = $1
+		ORDER BY g.name`, id); err != nil {
+		return domain.Movie{}, err
+	}
+
+	fields, err := patch(movie)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	if len(fields) == 0 {
+		return movie, nil
+	}
+
+	genres, setGenres := fields["genres"].([]domain.Genre)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if name == "genres" && setGenres {
+			continue
+		}
+		if _, ok := moviePatchColumns[name]; !ok {
+			return domain.Movie{}, fmt.Errorf("movie field %q can't be updated", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		set := make([]string, 0, len(names))
+		args := make([]interface{}, 0, len(names)+1)
+		for _, name := range names {
+			args = append(args, fields[name])
+			set = append(set, fmt.Sprintf("%s=$%d", moviePatchColumns[name], len(args)))
+		}
+		args = append(args, id)
+
+		query := fmt.Sprintf("UPDATE movie SET %s WHERE id=$%d RETURNING %s", strings.Join(set, ", "), len(args), movieColumns)
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&mMovie); err != nil {
+			if name, ok := fields["name"].(string); ok && isUniqueViolation(err) {
+				return domain.Movie{}, m.duplicateError(ctx, name)
+			}
+			return domain.Movie{}, err
+		}
+	}
+
+	if setGenres {
+		if err := setMovieGenres(ctx, tx, mMovie.ID, genres); err != nil {
+			return domain.Movie{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Movie{}, err
+	}
+
+	return mMovie.ToDomain(), nil
+}
+
+// setMovieGenres replaces the genres of the movie.
+func setMovieGenres(ctx context.Context, tx *sqlx.Tx, movieID int64, genres []domain.Genre) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movie_genres WHERE movie_id=$1", movieID); err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(genres))
+	for _, genre := range genres {
+		ids = append(ids, genre.ID)
+	}
+
+	_, err := tx.ExecContext(ctx, "INSERT INTO movie_genres (movie_id, genre_id) SELECT $1, unnest($2::int[])", movieID, pq.Array(ids))
+	// the genre has been deleted since the movie was validated
+	if isForeignKeyViolation(err) {
+		return domain.ErrGenreNotFound
+	}
+
+	return err
+}
+
+// duplicateError points at the movie that has taken the name. The movie may be gone by the time
+// it's looked up, then the plain conflict is returned.
+func (m Movie) duplicateError(ctx context.Context, name string) error {
+	var id int64
+	if err := m.db.GetContext(ctx, &id, "SELECT id FROM movie WHERE name=$1", name); err != nil {
+		return domain.ErrMovieNameTaken
+	}
+
+	return &domain.DuplicateMovieError{ExistingID: id}
+}
+
 func (m Movie) Delete(ctx context.Context, id int) error {
-	if _, err := m.db.ExecContext(ctx, "DELETE FROM movie WHERE id=$1", id); err != nil {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM movie WHERE id=$1", id)
+	if err != nil {
 		return err
 	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrMovieNotFound
+	}
+
 	return nil
 }
 
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/internal/repository/models"
+)
+
+const (
+	snippetOpenTag  = "<mark>"
+	snippetCloseTag = "</mark>"
+	snippetContext  = 60
+)
+
+// snippetSource is the text the full-text search snippets are cut from. It's HTML-escaped
+// before the matches are marked, so the movie texts can't inject markup into the snippet.
+const snippetSource = `replace(replace(replace(replace(replace(
+			concat_ws(' / ', name, nullif(cast_names, ''), description),
+			'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// Search looks the query up in the full-text index over movie name, cast names and description.
+// The index is added by the search migrations.
+// The query uses the web search syntax: quoted phrases, "or" and "-" for negation.
+func (m Movie) Search(ctx context.Context, inp domain.MovieSearchInput) ([]domain.MovieSearchResult, int, error) {
+	var total int
+	if err := m.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM movie WHERE search @@ websearch_to_tsquery('simple', $1)", inp.Query); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + movieColumns + `,
+		ts_rank(search, q) AS rank,
+		ts_headline('simple', ` + snippetSource + `, q,
+			'StartSel=` + snippetOpenTag + `, StopSel=` + snippetCloseTag + `, MaxWords=35, MinWords=15, MaxFragments=2') AS snippet
+		FROM movie, websearch_to_tsquery('simple', $1) q
+		WHERE search @@ q
+		ORDER BY rank DESC, id
+		LIMIT $2 OFFSET $3`
+
+	var list []models.MovieSearchResult
+	if err := m.db.SelectContext(ctx, &list, query, inp.Query, inp.Limit, inp.Offset()); err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]domain.MovieSearchResult, 0, len(list))
+	for _, res := range list {
+		results = append(results, res.ToDomain())
+	}
+
+	return results, total, nil
+}
+
+// FindDuplicates returns the movies of the year whose names are similar to the name by trigrams,
+// ignoring case and diacritics. The most similar ones go first.
+func (m Movie) FindDuplicates(ctx context.Context, inp domain.MovieDuplicateCheckInput) ([]domain.MovieDuplicate, error) {
+	query := `SELECT ` + movieColumns + `, similarity(normalize_title(name), normalize_title($1)) AS similarity
+		FROM movie
+		WHERE production_year = $2 AND normalize_title(name) % normalize_title($1)
+		ORDER BY similarity DESC, id
+		LIMIT $3`
+
+	return m.selectDuplicates(ctx, query, inp.Name, inp.ProductionYear, domain.MaxMovieDuplicates)
+}
+
+func (m Movie) selectDuplicates(ctx context.Context, query string, args ...interface{}) ([]domain.MovieDuplicate, error) {
+	var list []models.MovieDuplicate
+	if err := m.db.SelectContext(ctx, &list, query, args...); err != nil {
+		return nil, err
+	}
+
+	duplicates := make([]domain.MovieDuplicate, 0, len(list))
+	for _, d := range list {
+		duplicates = append(duplicates, d.ToDomain())
+	}
+
+	return duplicates, nil
+}
+
+// ILikeMovie is the movie repository that searches by case-insensitive substring matching
+// instead of the full-text index, which also finds parts of words. It's the fallback for backends
+// without full-text search: it doesn't need the search migrations. All other methods are the Movie ones.
+type ILikeMovie struct {
+	Movie
+}
+
+func NewILikeMovie(db *sqlx.DB) *ILikeMovie {
+	return &ILikeMovie{Movie: Movie{db: db}}
+}
+
+// Search matches the query as a substring of movie name, cast names and description.
+// Matches in the name rank higher than matches in the cast, which rank higher than the description.
+func (m ILikeMovie) Search(ctx context.Context, inp domain.MovieSearchInput) ([]domain.MovieSearchResult, int, error) {
+	pattern := "%" + escapeLike(inp.Query) + "%"
+
+	var total int
+	if err := m.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM movie WHERE name ILIKE $1 OR cast_names ILIKE $1 OR description ILIKE $1", pattern); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + movieColumns + `, cast_names,
+		(CASE WHEN name ILIKE $1 THEN 0.6 ELSE 0 END +
+		 CASE WHEN cast_names ILIKE $1 THEN 0.3 ELSE 0 END +
+		 CASE WHEN description ILIKE $1 THEN 0.1 ELSE 0 END) AS rank,
+		'' AS snippet
+		FROM movie
+		WHERE name ILIKE $1 OR cast_names ILIKE $1 OR description ILIKE $1
+		ORDER BY rank DESC, id
+		LIMIT $2 OFFSET $3`
+
+	var list []models.MovieSearchResult
+	if err := m.db.SelectContext(ctx, &list, query, pattern, inp.Limit, inp.Offset()); err != nil {
+		return nil, 0, err
+	}
+
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(inp.Query))
+
+	results := make([]domain.MovieSearchResult, 0, len(list))
+	for _, res := range list {
+		res.Snippet = highlight(re, res.Name, res.CastNames, res.Description)
+		results = append(results, res.ToDomain())
+	}
+
+	return results, total, nil
+}
+
+// highlight cuts a fragment around the first match found in texts, escapes it and marks the matches in it.
+func highlight(re *regexp.Regexp, texts ...string) string {
+	for _, text := range texts {
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+
+		start, end := loc[0], loc[1]
+		for i := 0; i < snippetContext && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+		for i := 0; i < snippetContext && end < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+
+		fragment := text[start:end]
+
+		var b strings.Builder
+		last := 0
+		for _, m := range re.FindAllStringIndex(fragment, -1) {
+			b.WriteString(html.EscapeString(fragment[last:m[0]]))
+			b.WriteString(snippetOpenTag + html.EscapeString(fragment[m[0]:m[1]]) + snippetCloseTag)
+			last = m[1]
+		}
+		b.WriteString(html.EscapeString(fragment[last:]))
+
+		return b.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,10 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
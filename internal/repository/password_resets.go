@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type PasswordResets struct {
+	db *sqlx.DB
+}
+
+func NewPasswordResets(db *sqlx.DB) *PasswordResets {
+	return &PasswordResets{db: db}
+}
+
+// Create stores the token. Only the latest token of the user is valid, the previous ones are removed.
+func (r PasswordResets) Create(ctx context.Context, token domain.PasswordResetToken) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id=$1 OR expires_at < now()", token.UserID); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		token.UserID, token.TokenHash, token.ExpiresAt)
+
+	return err
+}
+
+// Consume removes the unexpired token with the given hash and returns it. It returns
+// domain.ErrInvalidToken if there is no such token, so a token can be consumed only once.
+func (r PasswordResets) Consume(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	var t domain.PasswordResetToken
+	err := r.db.QueryRowContext(ctx, `DELETE FROM password_reset_tokens WHERE token_hash=$1 AND expires_at > now()
+		RETURNING id, user_id, token_hash, created_at, expires_at`, tokenHash).
+		Scan(&t.ID, &t.UserID, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.PasswordResetToken{}, domain.ErrInvalidToken
+	}
+
+	return t, err
+}
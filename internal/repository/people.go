@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+const personColumns = "id, name, bio, created_at"
+
+type People struct {
+	db *sqlx.DB
+}
+
+func NewPeople(db *sqlx.DB) *People {
+	return &People{db: db}
+}
+
+func (r People) Create(ctx context.Context, inp domain.PersonInput) (domain.Person, error) {
+	return scanPerson(r.db.QueryRowContext(ctx, "INSERT INTO people (name, bio) VALUES ($1, $2) RETURNING "+personColumns, inp.Name, inp.Bio))
+}
+
+// Get returns the person, domain.ErrPersonNotFound if there is none.
+func (r People) Get(ctx context.Context, id int64) (domain.Person, error) {
+	p, err := scanPerson(r.db.QueryRowContext(ctx, "SELECT "+personColumns+" FROM people WHERE id=$1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Person{}, domain.ErrPersonNotFound
+	}
+
+	return p, err
+}
+
+// List returns a page of people ordered by name, the name filter matches a part of the name ignoring case.
+func (r People) List(ctx context.Context, inp domain.ListPeopleInput) ([]domain.Person, int, error) {
+	pattern := "%" + escapeLike(inp.Name) + "%"
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM people WHERE name ILIKE $1", pattern); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+personColumns+" FROM people WHERE name ILIKE $1 ORDER BY name, id LIMIT $2 OFFSET $3",
+		pattern, inp.Limit, inp.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	people := make([]domain.Person, 0)
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		people = append(people, p)
+	}
+
+	return people, total, rows.Err()
+}
+
+func (r People) Update(ctx context.Context, id int64, inp domain.PersonInput) (domain.Person, error) {
+	p, err := scanPerson(r.db.QueryRowContext(ctx, "UPDATE people SET name=$1, bio=$2 WHERE id=$3 RETURNING "+personColumns, inp.Name, inp.Bio, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Person{}, domain.ErrPersonNotFound
+	}
+
+	return p, err
+}
+
+// Delete removes the person. The person is removed from the casts by the database cascades.
+func (r People) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM people WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrPersonNotFound
+	}
+
+	return nil
+}
+
+// Movies returns the filmography of the person, the latest movies first. Roles in the cast
+// go before the jobs in the crew of the same movie.
+func (r People) Movies(ctx context.Context, id int64) ([]domain.PersonMovie, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT m.id, m.name, m.production_year, credit.character_name, credit.billing, credit.department, credit.job
+		FROM (SELECT movie_id, character_name, billing, '' AS department, '' AS job, 0 AS crew, billing AS position
+				FROM movie_cast WHERE person_id=$1
+				UNION ALL
+				SELECT movie_id, '', 0, department, job, 1, position
+				FROM movie_crew WHERE person_id=$1) AS credit
+			JOIN movie m ON m.id = credit.movie_id
+		ORDER BY m.production_year DESC, m.id, credit.crew, credit.position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := make([]domain.PersonMovie, 0)
+	for rows.Next() {
+		var m domain.PersonMovie
+		if err := rows.Scan(&m.MovieID, &m.Name, &m.ProductionYear, &m.Character, &m.Billing, &m.Department, &m.Job); err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, m)
+	}
+
+	return movies, rows.Err()
+}
+
+func scanPerson(row scanner) (domain.Person, error) {
+	var p domain.Person
+	err := row.Scan(&p.ID, &p.Name, &p.Bio, &p.CreatedAt)
+
+	return p, err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// Ratings stores the votes of users. The vote counts and sums of the movies
+// are kept up to date by a trigger on the ratings table.
+type Ratings struct {
+	db *sqlx.DB
+}
+
+func NewRatings(db *sqlx.DB) *Ratings {
+	return &Ratings{db: db}
+}
+
+// Set saves the vote of the user for the movie, replacing the previous one.
+func (r Ratings) Set(ctx context.Context, movieID, userID int64, rating int) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO movie_ratings (movie_id, user_id, rating) VALUES ($1, $2, $3)
+		ON CONFLICT (movie_id, user_id) DO UPDATE SET rating = excluded.rating, rated_at = now()`, movieID, userID, rating)
+	// the user is authenticated, so the missing row can only be the movie
+	if isForeignKeyViolation(err) {
+		return domain.ErrMovieNotFound
+	}
+
+	return err
+}
+
+func (r Ratings) Delete(ctx context.Context, movieID, userID int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM movie_ratings WHERE movie_id=$1 AND user_id=$2", movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrRatingNotFound
+	}
+
+	return nil
+}
+
+// ListByUser returns the votes of the user for the movies, keyed by the movie id.
+func (r Ratings) ListByUser(ctx context.Context, userID int64, movieIDs []int64) (map[int64]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT movie_id, rating FROM movie_ratings WHERE user_id=$1 AND movie_id = ANY($2)", userID, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make(map[int64]int, len(movieIDs))
+	for rows.Next() {
+		var movieID int64
+		var rating int
+		if err := rows.Scan(&movieID, &rating); err != nil {
+			return nil, err
+		}
+
+		ratings[movieID] = rating
+	}
+
+	return ratings, rows.Err()
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// reviewQuery selects the reviews of the relation with the names of their authors.
+// The relation is the table or a data-modifying CTE that returns the changed rows.
+const reviewQuery = `SELECT r.id, r.movie_id, r.user_id, u.name, r.title, r.body, r.status, r.moderation_note,
+		r.helpful_count, r.unhelpful_count, r.created_at, r.updated_at
+	FROM %s r JOIN users u ON u.id = r.user_id`
+
+type Reviews struct {
+	db *sqlx.DB
+}
+
+func NewReviews(db *sqlx.DB) *Reviews {
+	return &Reviews{db: db}
+}
+
+func (r Reviews) Create(ctx context.Context, movieID, userID int64, inp domain.ReviewInput) (domain.Review, error) {
+	query := `WITH changed AS (INSERT INTO reviews (movie_id, user_id, title, body) VALUES ($1, $2, $3, $4) RETURNING *) ` +
+		fmt.Sprintf(reviewQuery, "changed")
+
+	review, err := scanReview(r.db.QueryRowContext(ctx, query, movieID, userID, inp.Title, inp.Body))
+	// the user is authenticated, so the missing row can only be the movie
+	if isForeignKeyViolation(err) {
+		return domain.Review{}, domain.ErrMovieNotFound
+	}
+	if isUniqueViolation(err) {
+		return domain.Review{}, domain.ErrReviewExists
+	}
+
+	return review, err
+}
+
+// Get returns the review, domain.ErrReviewNotFound if there is none.
+func (r Reviews) Get(ctx context.Context, id int64) (domain.Review, error) {
+	review, err := scanReview(r.db.QueryRowContext(ctx, fmt.Sprintf(reviewQuery, "reviews")+" WHERE r.id=$1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Review{}, domain.ErrReviewNotFound
+	}
+
+	return review, err
+}
+
+// List returns a page of the reviews the filter selects.
+func (r Reviews) List(ctx context.Context, filter domain.ReviewFilter, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	if filter.MovieID > 0 {
+		args = append(args, filter.MovieID)
+		conds = append(conds, fmt.Sprintf("r.movie_id = $%d", len(args)))
+	}
+
+	if filter.UserID > 0 {
+		args = append(args, filter.UserID)
+		conds = append(conds, fmt.Sprintf("r.user_id = $%d", len(args)))
+	}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("r.status = $%d", len(args)))
+	}
+
+	var where string
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM reviews r"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	order := "DESC"
+	if filter.OldestFirst {
+		order = "ASC"
+	}
+
+	query := fmt.Sprintf(reviewQuery, "reviews") + where +
+		fmt.Sprintf(" ORDER BY r.created_at %s, r.id %s LIMIT $%d OFFSET $%d", order, order, len(args)+1, len(args)+2)
+	args = append(args, inp.Limit, inp.Offset())
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reviews := make([]domain.Review, 0)
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, total, rows.Err()
+}
+
+// Update replaces the text of the review and sends it back to moderation. The votes were given
+// to the old text, they are removed and the trigger brings the counts down to zero.
+func (r Reviews) Update(ctx context.Context, id int64, inp domain.ReviewInput) (domain.Review, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Review{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM review_votes WHERE review_id=$1", id); err != nil {
+		return domain.Review{}, err
+	}
+
+	query := `WITH changed AS (UPDATE reviews
+			SET title=$1, body=$2, status='pending', moderation_note='', moderated_by=NULL, moderated_at=NULL, updated_at=now()
+			WHERE id=$3 RETURNING *) ` +
+		fmt.Sprintf(reviewQuery, "changed")
+
+	review, err := scanReview(tx.QueryRowContext(ctx, query, inp.Title, inp.Body, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Review{}, domain.ErrReviewNotFound
+	}
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Review{}, err
+	}
+
+	return review, nil
+}
+
+// Moderate records the decision of the editor, domain.ErrReviewChanged if the review
+// has been edited since the time the decision was made on.
+func (r Reviews) Moderate(ctx context.Context, id, moderatorID int64, inp domain.ModerateReviewInput) (domain.Review, error) {
+	query := `WITH changed AS (UPDATE reviews
+			SET status=$1, moderation_note=$2, moderated_by=$3, moderated_at=now()
+			WHERE id=$4 AND updated_at=$5 RETURNING *) ` +
+		fmt.Sprintf(reviewQuery, "changed")
+
+	review, err := scanReview(r.db.QueryRowContext(ctx, query, inp.Status, inp.Note, moderatorID, id, inp.UpdatedAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.Get(ctx, id); err != nil {
+			return domain.Review{}, err
+		}
+		return domain.Review{}, domain.ErrReviewChanged
+	}
+
+	return review, err
+}
+
+// Delete removes the review together with its votes.
+func (r Reviews) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM reviews WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrReviewNotFound
+	}
+
+	return nil
+}
+
+// Vote saves the vote of the user for the review, replacing the previous one.
+// The vote counts of the review are kept up to date by a trigger.
+func (r Reviews) Vote(ctx context.Context, id, userID int64, helpful bool) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO review_votes (review_id, user_id, helpful) VALUES ($1, $2, $3)
+		ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = excluded.helpful, voted_at = now()`, id, userID, helpful)
+	// the review has been deleted since it was checked
+	if isForeignKeyViolation(err) {
+		return domain.ErrReviewNotFound
+	}
+
+	return err
+}
+
+func (r Reviews) DeleteVote(ctx context.Context, id, userID int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM review_votes WHERE review_id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrReviewVoteNotFound
+	}
+
+	return nil
+}
+
+func scanReview(row scanner) (domain.Review, error) {
+	var r domain.Review
+	err := row.Scan(&r.ID, &r.MovieID, &r.Author.ID, &r.Author.Name, &r.Title, &r.Body, &r.Status, &r.ModerationNote,
+		&r.HelpfulCount, &r.UnhelpfulCount, &r.CreatedAt, &r.UpdatedAt)
+
+	return r, err
+}
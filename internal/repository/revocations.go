@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// Revocations is the denylist of access tokens revoked before they expired.
+// Entries are kept only until the revoked tokens would have expired anyway.
+type Revocations struct {
+	db *sqlx.DB
+}
+
+func NewRevocations(db *sqlx.DB) *Revocations {
+	return &Revocations{db: db}
+}
+
+// RevokeToken denies the access token with the given id until it expires.
+func (r Revocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING", jti, expiresAt); err != nil {
+		return err
+	}
+
+	return r.purge(ctx)
+}
+
+// RevokeSession denies every access token issued within the session until expiresAt,
+// when the last of those tokens expires.
+func (r Revocations) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	return r.RevokeToken(ctx, sessionRevocationKey(sessionID), expiresAt)
+}
+
+// RevokeUserTokens denies every access token of the user issued before issuedBefore.
+// The entry is needed only until expiresAt, when the last of those tokens expires.
+func (r Revocations) RevokeUserTokens(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `INSERT INTO revoked_user_tokens (user_id, issued_before, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET issued_before = EXCLUDED.issued_before, expires_at = EXCLUDED.expires_at`, userID, issuedBefore, expiresAt); err != nil {
+		return err
+	}
+
+	return r.purge(ctx)
+}
+
+func (r Revocations) IsRevoked(ctx context.Context, principal domain.Principal) (bool, error) {
+	var revoked bool
+	err := r.db.GetContext(ctx, &revoked, `SELECT
+		EXISTS(SELECT 1 FROM revoked_tokens WHERE jti IN ($1, $2) AND expires_at > now()) OR
+		EXISTS(SELECT 1 FROM revoked_user_tokens WHERE user_id = $3 AND issued_before > $4 AND expires_at > now())`,
+		principal.TokenID, sessionRevocationKey(principal.SessionID), principal.UserID, principal.IssuedAt)
+
+	return revoked, err
+}
+
+func (r Revocations) purge(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= now()"); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM revoked_user_tokens WHERE expires_at <= now()")
+
+	return err
+}
+
+// sessionRevocationKey keeps revoked sessions in the same denylist as token ids without clashing with them.
+func sessionRevocationKey(sessionID string) string {
+	return "session:" + sessionID
+}
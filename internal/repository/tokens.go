@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lukinairina90/crud_movies/internal/domain"
 )
 
+const refreshTokenColumns = "id, user_id, token, family_id, user_agent, ip, created_at, last_used_at, expires_at, rotated_at"
+
 type Tokens struct {
 	db *sqlx.DB
 }
@@ -15,20 +19,88 @@ func NewTokens(db *sqlx.DB) *Tokens {
 	return &Tokens{db: db}
 }
 
+// Create stores the token. A zero CreatedAt starts a new session, a rotated token passes on
+// the CreatedAt of the one it replaces.
 func (r Tokens) Create(ctx context.Context, token domain.RefreshSession) error {
-	_, err := r.db.Exec("INSERT INTO refresh_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)", token.UserID, token.Token, token.ExpiresAt)
+	// rotated tokens are kept for reuse detection only until they expire
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id=$1 AND expires_at < now()", token.UserID); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO refresh_tokens (user_id, token, family_id, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, now()), now(), $7)`,
+		token.UserID, token.Token, token.FamilyID, token.UserAgent, token.IP, nullTime(token.CreatedAt), token.ExpiresAt)
 
 	return err
 }
 
 func (r Tokens) Get(ctx context.Context, token string) (domain.RefreshSession, error) {
 	var t domain.RefreshSession
-	err := r.db.QueryRow("SELECT id, user_id, token, expires_at FROM refresh_tokens WHERE token=$1", token).Scan(&t.ID, &t.UserID, &t.Token, &t.ExpiresAt)
+	err := r.db.QueryRowContext(ctx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token=$1", token).
+		Scan(&t.ID, &t.UserID, &t.Token, &t.FamilyID, &t.UserAgent, &t.IP, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt, &t.RotatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.RefreshSession{}, domain.ErrInvalidRefreshToken
+	}
+
+	return t, err
+}
+
+// ListActive returns the current, not yet rotated token of every live session of the user.
+func (r Tokens) ListActive(ctx context.Context, userID int64) ([]domain.RefreshSession, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+refreshTokenColumns+` FROM refresh_tokens
+		WHERE user_id=$1 AND rotated_at IS NULL AND expires_at > now()
+		ORDER BY last_used_at DESC`, userID)
 	if err != nil {
-		return t, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	_, err = r.db.Exec("DELETE FROM refresh_tokens WHERE user_id=$1", t.UserID)
+	var sessions []domain.RefreshSession
+	for rows.Next() {
+		var t domain.RefreshSession
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Token, &t.FamilyID, &t.UserAgent, &t.IP, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt, &t.RotatedAt); err != nil {
+			return nil, err
+		}
 
-	return t, err
+		sessions = append(sessions, t)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Rotate marks the token as used. It reports false if the token had already been
+// rotated, which is how two concurrent refreshes with the same token are told apart.
+func (r Tokens) Rotate(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET rotated_at=now() WHERE id=$1 AND rotated_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// DeleteSession removes the token family of the user. It reports false if there was no such family.
+func (r Tokens) DeleteSession(ctx context.Context, userID int64, familyID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id=$1 AND family_id=$2", userID, familyID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n > 0, err
+}
+
+func (r Tokens) DeleteFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family_id=$1", familyID)
+
+	return err
+}
+
+func (r Tokens) DeleteByUser(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id=$1", userID)
+
+	return err
 }
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type TwoFactor struct {
+	db *sqlx.DB
+}
+
+func NewTwoFactor(db *sqlx.DB) *TwoFactor {
+	return &TwoFactor{db: db}
+}
+
+// Get returns the TOTP secret of the user, domain.ErrTwoFactorNotEnrolled if the user hasn't enrolled.
+func (r TwoFactor) Get(ctx context.Context, userID int64) (domain.TOTP, error) {
+	var t domain.TOTP
+	err := r.db.QueryRowContext(ctx, "SELECT user_id, secret, enabled_at, last_step FROM user_totp WHERE user_id=$1", userID).
+		Scan(&t.UserID, &t.Secret, &t.EnabledAt, &t.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.TOTP{}, domain.ErrTwoFactorNotEnrolled
+	}
+
+	return t, err
+}
+
+// Enroll stores a new secret that is not enabled yet. A pending enrollment is replaced,
+// an enabled one is kept and Enroll reports false.
+func (r TwoFactor) Enroll(ctx context.Context, userID int64, secret string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0 WHERE user_totp.enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// Enable enables the pending secret and replaces the recovery codes of the user.
+func (r TwoFactor) Enable(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_totp SET enabled_at=now(), last_step=$2 WHERE user_id=$1", userID, step); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records the time step of an accepted code. It reports false if a code of
+// the same or a later step has already been accepted.
+func (r TwoFactor) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE user_totp SET last_step=$2 WHERE user_id=$1 AND last_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// UseRecoveryCode removes the recovery code. It reports false if the user has no such code.
+func (r TwoFactor) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=$1 AND code_hash=$2", userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// UpdateSecret replaces the stored secret of the user, e.g. with the same secret encrypted.
+func (r TwoFactor) UpdateSecret(ctx context.Context, userID int64, secret string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE user_totp SET secret=$2 WHERE user_id=$1", userID, secret)
+
+	return err
+}
+
+// Delete disables two-factor authentication and removes the recovery codes.
+func (r TwoFactor) Delete(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
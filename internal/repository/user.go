@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lukinairina90/crud_movies/internal/domain"
@@ -15,16 +17,76 @@ func NewUsers(db *sqlx.DB) *Users {
 	return &Users{db: db}
 }
 
-func (r *Users) Create(ctx context.Context, user domain.User) error {
-	_, err := r.db.Exec("INSERT INTO users (name, email, password, registered_at) values ($1, $2, $3, $4)",
-		user.Name, user.Email, user.Password, user.RegisteredAt)
+const userColumns = "id, name, email, password, role, registered_at, verified_at"
 
-	return err
+func (r *Users) Create(ctx context.Context, user domain.User) (domain.User, error) {
+	err := r.db.QueryRowContext(ctx, "INSERT INTO users (name, email, password, registered_at, verified_at) values ($1, $2, $3, $4, $5) RETURNING "+userColumns,
+		user.Name, user.Email, user.Password, user.RegisteredAt, user.VerifiedAt).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.RegisteredAt, &user.VerifiedAt)
+	if isUniqueViolation(err) {
+		return domain.User{}, domain.ErrEmailTaken
+	}
+
+	return user, err
+}
+
+func (r *Users) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+	var user domain.User
+	err := r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email=$1", email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.RegisteredAt, &user.VerifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, domain.ErrAccountNotFound
+	}
+
+	return user, err
 }
 
-func (r *Users) GetByCredentials(ctx context.Context, email, password string) (domain.User, error) {
+func (r *Users) GetByID(ctx context.Context, id int64) (domain.User, error) {
 	var user domain.User
-	err := r.db.QueryRow("SELECT id, name, email, registered_at FROM users WHERE email=$1 AND password=$2", email, password).Scan(&user.ID, &user.Name, &user.Email, &user.RegisteredAt)
+	err := r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id=$1", id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.RegisteredAt, &user.VerifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, domain.ErrAccountNotFound
+	}
+
+	return user, err
+}
+
+func (r *Users) UpdatePassword(ctx context.Context, id int64, password string) error {
+	_, err := r.db.Exec("UPDATE users SET password=$1 WHERE id=$2", password, id)
+
+	return err
+}
+
+// Update changes the name and email of the user. A new email has to be verified again.
+func (r *Users) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	err := r.db.QueryRowContext(ctx, `UPDATE users SET name=$1, email=$2, verified_at=CASE WHEN email=$2 THEN verified_at END
+		WHERE id=$3 RETURNING `+userColumns, user.Name, user.Email, user.ID).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.RegisteredAt, &user.VerifiedAt)
+	if isUniqueViolation(err) {
+		return domain.User{}, domain.ErrEmailTaken
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, domain.ErrAccountNotFound
+	}
 
 	return user, err
 }
+
+// Delete removes the user. Sessions and other records of the user are removed by the database cascades.
+func (r *Users) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id=$1", id)
+
+	return err
+}
+
+// MarkVerified verifies the email of the user. It reports false if the email has changed
+// or has been verified already.
+func (r *Users) MarkVerified(ctx context.Context, id int64, email string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET verified_at=now() WHERE id=$1 AND email=$2 AND verified_at IS NULL", id, email)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
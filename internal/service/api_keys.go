@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// apiKeyPrefix marks the keys of this application, which helps secret scanners to find leaked keys.
+const apiKeyPrefix = "cmk_"
+
+type APIKeysRepository interface {
+	Create(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	ListByUser(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (domain.APIKey, error)
+	Touch(ctx context.Context, id int64) error
+	Delete(ctx context.Context, userID, id int64) (bool, error)
+}
+
+type APIKeys struct {
+	repo      APIKeysRepository
+	usersRepo UsersRepository
+	twoFactor TwoFactorRepository
+}
+
+func NewAPIKeys(repo APIKeysRepository, usersRepo UsersRepository, twoFactor TwoFactorRepository) *APIKeys {
+	return &APIKeys{repo: repo, usersRepo: usersRepo, twoFactor: twoFactor}
+}
+
+// Create generates a new key. The key is returned only here, just its hash is stored.
+func (s *APIKeys) Create(ctx context.Context, userID int64, inp domain.CreateAPIKeyInput) (domain.CreatedAPIKey, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.CreatedAPIKey{}, err
+	}
+
+	key := apiKeyPrefix + hex.EncodeToString(b)
+
+	created, err := s.repo.Create(ctx, domain.APIKey{
+		UserID:    userID,
+		Name:      inp.Name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(key),
+		Scopes:    inp.Scopes,
+		ExpiresAt: inp.ExpiresAt,
+	})
+	if err != nil {
+		return domain.CreatedAPIKey{}, err
+	}
+
+	return domain.CreatedAPIKey{APIKey: created, Key: key}, nil
+}
+
+func (s *APIKeys) List(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *APIKeys) Delete(ctx context.Context, userID, id int64) error {
+	deleted, err := s.repo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// ParseAPIKey resolves the key to its user. The role is read from the user, so a changed
+// role applies to the keys at once, the scopes of the key limit it further. So is the
+// two-factor authentication the editor and admin roles require.
+func (s *APIKeys) ParseAPIKey(ctx context.Context, key string) (domain.Principal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return domain.Principal{}, domain.ErrInvalidAPIKey
+	}
+
+	k, err := s.repo.GetByHash(ctx, hashToken(key))
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	if k.Expired(time.Now()) {
+		return domain.Principal{}, domain.ErrInvalidAPIKey
+	}
+
+	user, err := s.usersRepo.GetByID(ctx, k.UserID)
+	if errors.Is(err, domain.ErrAccountNotFound) {
+		return domain.Principal{}, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	var tfa bool
+	if user.Role.RequiresTwoFactor() {
+		if tfa, err = twoFactorEnabled(ctx, s.twoFactor, user.ID); err != nil {
+			return domain.Principal{}, err
+		}
+	}
+
+	// the request is authenticated anyway, failing to record the use only loses the statistics
+	if err := s.repo.Touch(ctx, k.ID); err != nil {
+		logrus.WithField("api_key_id", k.ID).Errorf("recording api key use failed: %s", err.Error())
+	}
+
+	return domain.Principal{
+		UserID:    user.ID,
+		Role:      user.Role,
+		TwoFactor: tfa,
+		APIKeyID:  k.ID,
+		Scopes:    k.Scopes,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// Genres manages the genre taxonomy movies are classified by.
+type Genres struct {
+	genresRepository GenresRepository
+}
+
+func NewGenres(genresRepository GenresRepository) *Genres {
+	return &Genres{genresRepository: genresRepository}
+}
+
+func (g Genres) List(ctx context.Context) ([]domain.Genre, error) {
+	return g.genresRepository.List(ctx)
+}
+
+func (g Genres) Get(ctx context.Context, slug string) (domain.Genre, error) {
+	return g.genresRepository.Get(ctx, slug)
+}
+
+func (g Genres) Create(ctx context.Context, inp domain.GenreInput) (domain.Genre, error) {
+	return g.genresRepository.Create(ctx, inp)
+}
+
+func (g Genres) Update(ctx context.Context, slug string, inp domain.GenreInput) (domain.Genre, error) {
+	return g.genresRepository.Update(ctx, slug, inp)
+}
+
+// Delete removes a genre no movie uses, used genres are merged into others instead.
+func (g Genres) Delete(ctx context.Context, slug string) error {
+	return g.genresRepository.Delete(ctx, slug)
+}
+
+// Merge moves the movies of the genre to the other one and removes the genre,
+// which cleans up misspelled or redundant genres.
+func (g Genres) Merge(ctx context.Context, slug string, inp domain.MergeGenreInput) (domain.Genre, error) {
+	if slug == inp.Into {
+		return domain.Genre{}, domain.ErrGenreSelfMerge
+	}
+
+	return g.genresRepository.Merge(ctx, slug, inp.Into)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+type LoginAttemptsRepository interface {
+	Get(ctx context.Context, key string) (domain.LoginAttempts, error)
+	Fail(ctx context.Context, key string, window time.Duration) (domain.LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	UnlockExpired(ctx context.Context, prefix string) ([]domain.LoginAttempts, error)
+	DeleteExpired(ctx context.Context, prefix string, window time.Duration) error
+}
+
+// LockoutPolicy limits the failed sign-in attempts. Once a key reaches its limit, every further
+// failure locks it out for twice as long as the previous one, starting from BaseLockout up to MaxLockout.
+// The counter starts over after Window without failures.
+type LockoutPolicy struct {
+	AccountMaxFailures int
+	IPMaxFailures      int
+	Window             time.Duration
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+}
+
+// LoginThrottle counts failed sign-ins per account and per client address and locks them out
+// when there are too many. Lockouts and unlocks are logged as audit events.
+type LoginThrottle struct {
+	repo   LoginAttemptsRepository
+	policy LockoutPolicy
+	// scope keeps the counters apart from the other throttles sharing the repository,
+	// it prefixes the keys and names the audit events.
+	scope string
+}
+
+func NewLoginThrottle(repo LoginAttemptsRepository, policy LockoutPolicy) *LoginThrottle {
+	return &LoginThrottle{repo: repo, policy: policy, scope: "sign_in"}
+}
+
+// NewPasswordResetThrottle limits the password reset requests per email and per client address.
+// Every request counts as a failure, whether the email is registered or not.
+func NewPasswordResetThrottle(repo LoginAttemptsRepository, policy LockoutPolicy) *LoginThrottle {
+	return &LoginThrottle{repo: repo, policy: policy, scope: "password_reset"}
+}
+
+// Check returns a *domain.TooManyAttemptsError if the account or the client address is locked out.
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) error {
+	var retryAfter time.Duration
+	now := time.Now()
+
+	for _, key := range t.keys(email, ip) {
+		a, err := t.repo.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if a.Locked(now) && a.LockedUntil.Sub(now) > retryAfter {
+			retryAfter = a.LockedUntil.Sub(now)
+		}
+	}
+
+	if retryAfter > 0 {
+		return &domain.TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// Failed counts a failed attempt for the account and the client address.
+func (t *LoginThrottle) Failed(ctx context.Context, email, ip string) error {
+	for _, key := range t.keys(email, ip) {
+		a, err := t.repo.Fail(ctx, key, t.policy.Window)
+		if err != nil {
+			return err
+		}
+
+		maxFailures := t.policy.AccountMaxFailures
+		if strings.HasPrefix(key, t.scope+":ip:") {
+			maxFailures = t.policy.IPMaxFailures
+		}
+
+		if a.Failures < maxFailures {
+			continue
+		}
+
+		until := time.Now().Add(t.lockout(a.Failures - maxFailures))
+		if err := t.repo.Lock(ctx, key, until); err != nil {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"audit":        t.scope + "_locked",
+			"key":          key,
+			"failures":     a.Failures,
+			"locked_until": until,
+		}).Warn("locked out")
+	}
+
+	return nil
+}
+
+// Succeeded starts the counter of the account over. The counter of the client address is kept,
+// signing in to one account must not reset the attempts on others.
+func (t *LoginThrottle) Succeeded(ctx context.Context, email string) error {
+	key := t.accountKey(email)
+
+	a, err := t.repo.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if a.Failures == 0 {
+		return nil
+	}
+
+	if err := t.repo.Reset(ctx, key); err != nil {
+		return err
+	}
+
+	if a.LockedUntil != nil {
+		logrus.WithFields(logrus.Fields{
+			"audit":    t.scope + "_unlocked",
+			"key":      key,
+			"failures": a.Failures,
+		}).Info("unlocked")
+	}
+
+	return nil
+}
+
+// RunCleanup unlocks the ended lockouts and removes the expired counters every interval, apart
+// from the sign-in requests. It returns when the context is done.
+func (t *LoginThrottle) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Cleanup(ctx); err != nil {
+				logrus.WithField("scope", t.scope).Errorf("login attempts cleanup failed: %s", err.Error())
+			}
+		}
+	}
+}
+
+// Cleanup logs the unlock of every key whose lockout has ended and removes the counters
+// that had no failures within the window.
+func (t *LoginThrottle) Cleanup(ctx context.Context) error {
+	unlocked, err := t.repo.UnlockExpired(ctx, t.scope+":")
+	if err != nil {
+		return err
+	}
+
+	for _, a := range unlocked {
+		logrus.WithFields(logrus.Fields{
+			"audit":    t.scope + "_unlocked",
+			"key":      a.Key,
+			"failures": a.Failures,
+		}).Info("unlocked")
+	}
+
+	return t.repo.DeleteExpired(ctx, t.scope+":", t.policy.Window)
+}
+
+// lockout doubles the base lockout for every failure over the limit.
+func (t *LoginThrottle) lockout(overLimit int) time.Duration {
+	d := t.policy.BaseLockout
+	for i := 0; i < overLimit && d < t.policy.MaxLockout; i++ {
+		d *= 2
+	}
+
+	if d > t.policy.MaxLockout {
+		d = t.policy.MaxLockout
+	}
+
+	return d
+}
+
+func (t *LoginThrottle) keys(email, ip string) []string {
+	keys := []string{t.accountKey(email)}
+	if ip != "" {
+		keys = append(keys, t.scope+":ip:"+ip)
+	}
+
+	return keys
+}
+
+func (t *LoginThrottle) accountKey(email string) string {
+	return t.scope + ":account:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -1,44 +1,146 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/jsonpatch"
 	"github.com/lukinairina90/in_memory_cache/generic_cache"
 )
 
 const movieKeyPattern = "movie:%d"
 
 type MoviesRepository interface {
-	List(ctx context.Context) (domain.ListMovie, error)
+	List(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, error)
+	ListAfter(ctx context.Context, inp domain.ListMoviesInput, cursor *domain.MovieCursor) (domain.ListMovie, int, error)
 	Get(ctx context.Context, id int) (domain.Movie, error)
+	Search(ctx context.Context, inp domain.MovieSearchInput) ([]domain.MovieSearchResult, int, error)
+	FindDuplicates(ctx context.Context, inp domain.MovieDuplicateCheckInput) ([]domain.MovieDuplicate, error)
 	Create(ctx context.Context, movie domain.Movie) (domain.Movie, error)
 	Update(ctx context.Context, id int, movie domain.Movie) (domain.Movie, error)
+	Patch(ctx context.Context, id int, patch func(domain.Movie) (map[string]interface{}, error)) (domain.Movie, error)
 	Delete(ctx context.Context, id int) error
 }
 
+type CastRepository interface {
+	ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.CastMember, error)
+	Set(ctx context.Context, movieID int64, cast []domain.CastMemberInput) error
+}
+
+type CrewRepository interface {
+	ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.CrewMember, error)
+	Set(ctx context.Context, movieID int64, crew []domain.CrewMemberInput) error
+}
+
+type RatingsRepository interface {
+	Set(ctx context.Context, movieID, userID int64, rating int) error
+	Delete(ctx context.Context, movieID, userID int64) error
+	ListByUser(ctx context.Context, userID int64, movieIDs []int64) (map[int64]int, error)
+}
+
+type GenresRepository interface {
+	List(ctx context.Context) ([]domain.Genre, error)
+	Get(ctx context.Context, slug string) (domain.Genre, error)
+	GetBySlugs(ctx context.Context, slugs []string) ([]domain.Genre, error)
+	ListByMovies(ctx context.Context, movieIDs []int64) (map[int64][]domain.Genre, error)
+	Create(ctx context.Context, inp domain.GenreInput) (domain.Genre, error)
+	Update(ctx context.Context, slug string, inp domain.GenreInput) (domain.Genre, error)
+	Delete(ctx context.Context, slug string) error
+	Merge(ctx context.Context, slug, into string) (domain.Genre, error)
+}
+
 type Cacher[K comparable, V any] interface {
 	Set(key K, value V, ttl time.Duration) error
 	Get(key K) (V, error)
 	Delete(key K) error
 }
 
+type CursorSigner interface {
+	Encode(v interface{}) (string, error)
+	Decode(token string, v interface{}) error
+}
+
 type Movie struct {
-	movieRepository MoviesRepository
-	cache           Cacher[string, domain.Movie]
+	movieRepository   MoviesRepository
+	castRepository    CastRepository
+	crewRepository    CrewRepository
+	genresRepository  GenresRepository
+	ratingsRepository RatingsRepository
+	cache             Cacher[string, domain.Movie]
+	cursors           CursorSigner
 }
 
-func NewMovie(movieRepository MoviesRepository, cacher Cacher[string, domain.Movie]) *Movie {
+func NewMovie(movieRepository MoviesRepository, castRepository CastRepository, crewRepository CrewRepository, genresRepository GenresRepository, ratingsRepository RatingsRepository, cacher Cacher[string, domain.Movie], cursors CursorSigner) *Movie {
 	return &Movie{
-		movieRepository: movieRepository,
-		cache:           cacher,
+		movieRepository:   movieRepository,
+		castRepository:    castRepository,
+		crewRepository:    crewRepository,
+		genresRepository:  genresRepository,
+		ratingsRepository: ratingsRepository,
+		cache:             cacher,
+		cursors:           cursors,
 	}
 }
 
-func (m Movie) List(ctx context.Context) (domain.ListMovie, error) {
-	return m.movieRepository.List(ctx)
+func (m Movie) List(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, error) {
+	movies, total, err := m.movieRepository.List(ctx, inp.WithDefaults())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := m.withDetails(ctx, movies.Refs()...); err != nil {
+		return nil, 0, err
+	}
+
+	return movies, total, nil
+}
+
+// ListByCursor returns a keyset page of movies and the signed cursor of the next page.
+// The sort order is taken from the cursor once paging has started, so it can't drift between pages.
+func (m Movie) ListByCursor(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, string, error) {
+	inp = inp.WithDefaults()
+
+	var after *domain.MovieCursor
+	if inp.Cursor != "" {
+		var cursor domain.MovieCursor
+		if err := m.cursors.Decode(inp.Cursor, &cursor); err != nil {
+			return nil, 0, "", domain.ErrInvalidCursor
+		}
+
+		inp.SortBy, inp.Order = cursor.SortBy, cursor.Order
+		after = &cursor
+	}
+
+	limit := inp.Limit
+	inp.Limit++ // one extra row tells whether there is a next page
+
+	movies, total, err := m.movieRepository.ListAfter(ctx, inp, after)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	if err := m.withDetails(ctx, movies.Refs()...); err != nil {
+		return nil, 0, "", err
+	}
+
+	if len(movies) <= limit {
+		return movies, total, "", nil
+	}
+
+	movies = movies[:limit]
+
+	next, err := m.cursors.Encode(domain.NewMovieCursor(movies[limit-1], inp.SortBy, inp.Order))
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return movies, total, next, nil
 }
 
 func (m Movie) Get(ctx context.Context, id int) (domain.Movie, error) {
@@ -58,24 +160,333 @@ func (m Movie) Get(ctx context.Context, id int) (domain.Movie, error) {
 		}
 	}
 
+	// the credits and genres aren't cached, people and genres can change without the movie knowing it
+	if err := m.withDetails(ctx, &res); err != nil {
+		return domain.Movie{}, err
+	}
+
 	return res, nil
 }
 
-func (m Movie) Create(ctx context.Context, movie domain.Movie) (domain.Movie, error) {
-	return m.movieRepository.Create(ctx, movie)
+func (m Movie) Search(ctx context.Context, inp domain.MovieSearchInput) ([]domain.MovieSearchResult, int, error) {
+	results, total, err := m.movieRepository.Search(ctx, inp.WithDefaults())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	movies := make([]*domain.Movie, 0, len(results))
+	for i := range results {
+		movies = append(movies, &results[i].Movie)
+	}
+
+	if err := m.withDetails(ctx, movies...); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+func (m Movie) FindDuplicates(ctx context.Context, inp domain.MovieDuplicateCheckInput) ([]domain.MovieDuplicate, error) {
+	duplicates, err := m.movieRepository.FindDuplicates(ctx, inp)
+	if err != nil {
+		return nil, err
+	}
+
+	movies := make([]*domain.Movie, 0, len(duplicates))
+	for i := range duplicates {
+		movies = append(movies, &duplicates[i].Movie)
+	}
+
+	// the cast helps to tell a remake from a duplicate
+	if err := m.withDetails(ctx, movies...); err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
+}
+
+func (m Movie) Create(ctx context.Context, inp domain.MovieInput) (domain.Movie, error) {
+	genres, err := m.resolveGenres(ctx, inp.Genres)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	movie := inp.Movie()
+	movie.Genres = genres
+
+	created, err := m.movieRepository.Create(ctx, movie)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	created.Genres = genres
+	created.Cast = []domain.CastMember{}
+	created.Crew = []domain.CrewMember{}
+
+	return created, nil
 }
 
-func (m Movie) Update(ctx context.Context, id int, movie domain.Movie) (domain.Movie, error) {
-	movie, err := m.movieRepository.Update(ctx, id, movie)
+func (m Movie) Update(ctx context.Context, id int, inp domain.MovieInput) (domain.Movie, error) {
+	genres, err := m.resolveGenres(ctx, inp.Genres)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	update := inp.Movie()
+	update.Genres = genres
+
+	movie, err := m.movieRepository.Update(ctx, id, update)
 	if err != nil {
 		return domain.Movie{}, err
 	}
 
-	if err := m.cache.Delete(fmt.Sprintf(movieKeyPattern, id)); err != nil {
+	if err := m.invalidate(id); err != nil {
+		return domain.Movie{}, err
+	}
+
+	if err := m.withDetails(ctx, &movie); err != nil {
 		return domain.Movie{}, err
 	}
 
-	return movie, err
+	return movie, nil
+}
+
+// SetCast replaces the cast of the movie and returns the movie with the new cast.
+func (m Movie) SetCast(ctx context.Context, id int, inp domain.SetCastInput) (domain.Movie, error) {
+	if err := m.castRepository.Set(ctx, int64(id), inp.Cast); err != nil {
+		return domain.Movie{}, err
+	}
+
+	return m.Get(ctx, id)
+}
+
+// SetCrew replaces the crew of the movie and returns the movie with the new crew.
+// The filterable jobs are stored under their own names.
+func (m Movie) SetCrew(ctx context.Context, id int, inp domain.SetCrewInput) (domain.Movie, error) {
+	if err := m.crewRepository.Set(ctx, int64(id), inp.WithCanonicalJobs().Crew); err != nil {
+		return domain.Movie{}, err
+	}
+
+	return m.Get(ctx, id)
+}
+
+// Rate saves the vote of the user for the movie and returns the movie with the updated rating.
+func (m Movie) Rate(ctx context.Context, id int, userID int64, inp domain.RatingInput) (domain.Movie, error) {
+	if err := m.ratingsRepository.Set(ctx, int64(id), userID, inp.Rating); err != nil {
+		return domain.Movie{}, err
+	}
+
+	// the cached movie has the old rating
+	if err := m.invalidate(id); err != nil {
+		return domain.Movie{}, err
+	}
+
+	movie, err := m.Get(ctx, id)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	movie.UserRating = &inp.Rating
+
+	return movie, nil
+}
+
+// Unrate withdraws the vote of the user for the movie.
+func (m Movie) Unrate(ctx context.Context, id int, userID int64) error {
+	if err := m.ratingsRepository.Delete(ctx, int64(id), userID); err != nil {
+		return err
+	}
+
+	return m.invalidate(id)
+}
+
+// UserRatings fills in the votes of the user for the movies. The votes are personal,
+// so they are filled in for each caller instead of being loaded with the movies.
+func (m Movie) UserRatings(ctx context.Context, userID int64, movies ...*domain.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	ratings, err := m.ratingsRepository.ListByUser(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if rating, ok := ratings[movie.ID]; ok {
+			movie.UserRating = &rating
+		}
+	}
+
+	return nil
+}
+
+// resolveGenres looks up the genres by their slugs, all of them have to be in the taxonomy.
+func (m Movie) resolveGenres(ctx context.Context, slugs []string) ([]domain.Genre, error) {
+	genres, err := m.genresRepository.GetBySlugs(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(genres) == len(slugs) {
+		return genres, nil
+	}
+
+	found := make(map[string]bool, len(genres))
+	for _, genre := range genres {
+		found[genre.Slug] = true
+	}
+
+	var unknown []string
+	for _, slug := range slugs {
+		if !found[slug] {
+			unknown = append(unknown, slug)
+		}
+	}
+
+	return nil, domain.NewUnknownGenresError(unknown)
+}
+
+// withDetails loads the genres, casts and crews of the movies.
+func (m Movie) withDetails(ctx context.Context, movies ...*domain.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	genres, err := m.genresRepository.ListByMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	casts, err := m.castRepository.ListByMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	crews, err := m.crewRepository.ListByMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Genres = genres[movie.ID]
+		if movie.Genres == nil {
+			movie.Genres = []domain.Genre{}
+		}
+
+		movie.Cast = casts[movie.ID]
+		if movie.Cast == nil {
+			movie.Cast = []domain.CastMember{}
+		}
+
+		movie.Crew = crews[movie.ID]
+		if movie.Crew == nil {
+			movie.Crew = []domain.CrewMember{}
+		}
+	}
+
+	return nil
+}
+
+// Patch applies the merge patch or JSON Patch to the movie and stores only the fields it has changed.
+// The patched movie is validated as a whole, like a full update. The movie is locked while the patch
+// is applied, so the test operations see the movie that is written over.
+func (m Movie) Patch(ctx context.Context, id int, patch domain.MoviePatch) (domain.Movie, error) {
+	var changed bool
+	movie, err := m.movieRepository.Patch(ctx, id, func(movie domain.Movie) (map[string]interface{}, error) {
+		changes, err := m.patchChanges(ctx, movie.Input(), patch)
+		changed = len(changes) > 0
+
+		return changes, err
+	})
+	if err != nil {
+		return domain.Movie{}, err
+	}
+
+	if changed {
+		if err := m.invalidate(id); err != nil {
+			return domain.Movie{}, err
+		}
+	}
+
+	if err := m.withDetails(ctx, &movie); err != nil {
+		return domain.Movie{}, err
+	}
+
+	return movie, nil
+}
+
+// patchChanges applies the patch to the movie input and returns the fields it has changed,
+// with the genres resolved.
+func (m Movie) patchChanges(ctx context.Context, before domain.MovieInput, patch domain.MoviePatch) (map[string]interface{}, error) {
+	doc, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch patch.Format {
+	case domain.MergePatch:
+		patched, err = jsonpatch.MergePatch(doc, patch.Document)
+	case domain.JSONPatch:
+		patched, err = jsonpatch.Apply(doc, patch.Document)
+	default:
+		return nil, fmt.Errorf("unknown patch format %d", patch.Format)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, domain.ErrPatchTestFailed
+	}
+	if err != nil {
+		return nil, domain.NewValidationError("invalid_patch", err.Error(), nil)
+	}
+
+	after, err := decodeMovieInput(patched)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := after.Validate(); err != nil {
+		return nil, err
+	}
+
+	changes := before.Changes(after)
+	if slugs, ok := changes["genres"].([]string); ok {
+		if changes["genres"], err = m.resolveGenres(ctx, slugs); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// decodeMovieInput decodes the patched movie. Fields the movie doesn't have and values
+// of the wrong type are reported as validation errors.
+func decodeMovieInput(data []byte) (domain.MovieInput, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	var inp domain.MovieInput
+	if err := d.Decode(&inp); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			fields := map[string]string{typeErr.Field: "must be of type " + typeErr.Type.String()}
+			return domain.MovieInput{}, domain.NewValidationError("validation_failed", "validation error", fields)
+		}
+
+		return domain.MovieInput{}, domain.NewValidationError("invalid_patch", strings.TrimPrefix(err.Error(), "json: "), nil)
+	}
+
+	return inp, nil
 }
 
 func (m Movie) Delete(ctx context.Context, id int) error {
@@ -83,5 +494,14 @@ func (m Movie) Delete(ctx context.Context, id int) error {
 		return err
 	}
 
-	return m.cache.Delete(fmt.Sprintf(movieKeyPattern, id))
+	return m.invalidate(id)
+}
+
+// invalidate drops the cached movie. A movie that hasn't been cached is fine.
+func (m Movie) invalidate(id int) error {
+	if err := m.cache.Delete(fmt.Sprintf(movieKeyPattern, id)); err != nil && !errors.Is(err, generic_cache.ErrKeyNotFound) {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/oidc"
+	"github.com/sirupsen/logrus"
+)
+
+const oidcStateTTL = time.Minute * 10
+
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (oidc.Claims, error)
+}
+
+type IdentitiesRepository interface {
+	Get(ctx context.Context, provider, subject string) (domain.Identity, error)
+	Create(ctx context.Context, identity domain.Identity) error
+}
+
+// StateSigner protects the login state the client holds between the login and the callback.
+type StateSigner interface {
+	Encode(v interface{}) (string, error)
+	Decode(token string, v interface{}) error
+}
+
+// oidcState is what the login has to remember until the callback: the state sent to the
+// provider, the nonce expected in the ID token and the PKCE verifier. LinkUserID is set
+// when the signed-in user started the login to link the provider's account.
+type oidcState struct {
+	State      string    `json:"s"`
+	Nonce      string    `json:"n"`
+	Verifier   string    `json:"v"`
+	LinkUserID int64     `json:"u,omitempty"`
+	ExpiresAt  time.Time `json:"e"`
+}
+
+// OIDC signs users in with an OpenID Connect provider and issues the application's own tokens.
+type OIDC struct {
+	users         *Users
+	identities    IdentitiesRepository
+	provider      OIDCProvider
+	stateSigner   StateSigner
+	providerName  string
+	autoProvision bool
+}
+
+func NewOIDC(users *Users, identities IdentitiesRepository, provider OIDCProvider, stateSigner StateSigner, providerName string, autoProvision bool) *OIDC {
+	return &OIDC{
+		users:         users,
+		identities:    identities,
+		provider:      provider,
+		stateSigner:   stateSigner,
+		providerName:  providerName,
+		autoProvision: autoProvision,
+	}
+}
+
+// Login returns the URL of the provider's login page and the signed state the client
+// has to bring back to the callback.
+func (s *OIDC) Login(ctx context.Context) (string, string, error) {
+	return s.login(ctx, 0)
+}
+
+// StartLink starts the login that links the provider's account to the signed-in user, the way
+// staff get to sign in with the identity provider. The user confirms it with the password and,
+// with two-factor authentication enabled, a code.
+func (s *OIDC) StartLink(ctx context.Context, userID int64, inp domain.LinkIdentityInput) (string, string, error) {
+	if err := s.users.reauthenticate(ctx, userID, inp.Password, inp.Code); err != nil {
+		return "", "", err
+	}
+
+	return s.login(ctx, userID)
+}
+
+func (s *OIDC) login(ctx context.Context, linkUserID int64) (string, string, error) {
+	st := oidcState{LinkUserID: linkUserID}
+	var err error
+	for _, v := range []*string{&st.State, &st.Nonce, &st.Verifier} {
+		if *v, err = oidc.RandomString(); err != nil {
+			return "", "", err
+		}
+	}
+	st.ExpiresAt = time.Now().Add(oidcStateTTL)
+
+	authURL, err := s.provider.AuthCodeURL(ctx, st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	signedState, err := s.stateSigner.Encode(st)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, signedState, nil
+}
+
+// Callback completes the login: it redeems the code, finds the user linked to the provider's
+// account and issues the token pair, or the MFA challenge token when the user has two-factor
+// authentication enabled. A login started by StartLink links the account to the user who started it.
+// Otherwise an unknown account is linked to the verified viewer with the same, provider verified email,
+// or a new user is created when auto-provisioning is on. Editors and admins have to link theirs.
+func (s *OIDC) Callback(ctx context.Context, code, state, signedState string, client domain.ClientInfo) (domain.SignInResult, error) {
+	var st oidcState
+	if err := s.stateSigner.Decode(signedState, &st); err != nil || time.Now().After(st.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 {
+		return domain.SignInResult{}, domain.ErrInvalidOIDCState
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, st.Verifier, st.Nonce)
+	if err != nil {
+		return domain.SignInResult{}, fmt.Errorf("%w: %v", domain.ErrOIDCLoginFailed, err)
+	}
+
+	var user domain.User
+	if st.LinkUserID != 0 {
+		user, err = s.linkUser(ctx, st.LinkUserID, claims)
+	} else {
+		user, err = s.resolveUser(ctx, claims)
+	}
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	// the provider's login doesn't replace the second factor enrolled here
+	mfaToken, err := s.users.mfaChallenge(ctx, user.ID)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	if mfaToken != "" {
+		return domain.SignInResult{MFAToken: mfaToken}, nil
+	}
+
+	accessToken, refreshToken, err := s.users.generateTokens(ctx, user, client)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	return domain.SignInResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func (s *OIDC) resolveUser(ctx context.Context, claims oidc.Claims) (domain.User, error) {
+	identity, err := s.identities.Get(ctx, s.providerName, claims.Subject)
+	if err == nil {
+		return s.users.Profile(ctx, identity.UserID)
+	}
+	if !errors.Is(err, domain.ErrAccountNotLinked) {
+		return domain.User{}, err
+	}
+
+	// an unverified email could be anyone's, it must not take over an account
+	if claims.Email == "" || !claims.EmailVerified {
+		return domain.User{}, domain.ErrAccountNotLinked
+	}
+
+	user, err := s.users.repo.GetByEmail(ctx, claims.Email)
+	switch {
+	case errors.Is(err, domain.ErrAccountNotFound):
+		if !s.autoProvision {
+			return domain.User{}, domain.ErrAccountNotLinked
+		}
+
+		if user, err = s.provisionUser(ctx, claims); err != nil {
+			return domain.User{}, err
+		}
+	case err != nil:
+		return domain.User{}, err
+	case user.VerifiedAt == nil:
+		// whoever registered the email here may not own it, linking would hand them the account
+		return domain.User{}, domain.ErrAccountNotLinked
+	case user.Role != domain.RoleViewer:
+		// the email alone is not enough to take over an editor or an admin, they link through StartLink
+		return domain.User{}, domain.ErrAccountNotLinked
+	}
+
+	if err := s.link(ctx, user.ID, claims); err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+// linkUser links the provider's account to the user who started the link. An account
+// linked to someone else stays theirs.
+func (s *OIDC) linkUser(ctx context.Context, userID int64, claims oidc.Claims) (domain.User, error) {
+	identity, err := s.identities.Get(ctx, s.providerName, claims.Subject)
+	switch {
+	case err == nil && identity.UserID != userID:
+		return domain.User{}, domain.ErrIdentityLinked
+	case err == nil:
+		return s.users.Profile(ctx, userID)
+	case !errors.Is(err, domain.ErrAccountNotLinked):
+		return domain.User{}, err
+	}
+
+	user, err := s.users.Profile(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if err := s.link(ctx, user.ID, claims); err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDC) link(ctx context.Context, userID int64, claims oidc.Claims) error {
+	if err := s.identities.Create(ctx, domain.Identity{
+		UserID:   userID,
+		Provider: s.providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"provider": s.providerName,
+	}).Info("identity linked")
+
+	return nil
+}
+
+// provisionUser creates a user for the provider's account. The user has no password
+// and signs in through the provider only, until a password is set by a password reset.
+func (s *OIDC) provisionUser(ctx context.Context, claims oidc.Claims) (domain.User, error) {
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+
+	now := time.Now()
+
+	return s.users.repo.Create(ctx, domain.User{
+		Name:         name,
+		Email:        claims.Email,
+		RegisteredAt: now,
+		VerifiedAt:   &now,
+	})
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/mail"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	passwordResetTokenTTL = time.Hour
+	// passwordResetTimeout bounds the background work of a password reset request.
+	passwordResetTimeout = time.Second * 30
+	// passwordResetWorkers is how many password resets are handled at once, more requests are dropped.
+	passwordResetWorkers = 8
+)
+
+// ForgotPassword emails the user a link to reset the password. Whether the email is registered
+// is not revealed: the lookup, the token and the email are handled in the background, so the
+// response takes the same time for unknown emails, and failures are only logged. The requests
+// are throttled per email and per client address, and dropped while every worker is busy.
+func (s *Users) ForgotPassword(ctx context.Context, email string, client domain.ClientInfo) error {
+	if err := s.resetThrottle.Check(ctx, email, client.IP); err != nil {
+		return err
+	}
+
+	if err := s.resetThrottle.Failed(ctx, email, client.IP); err != nil {
+		return err
+	}
+
+	select {
+	case s.resetSlots <- struct{}{}:
+	default:
+		logrus.Warn("password reset dropped, all the workers are busy")
+		return nil
+	}
+
+	go func() {
+		defer func() { <-s.resetSlots }()
+
+		// the request context is canceled as soon as the response is written
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+		defer cancel()
+
+		if err := s.sendPasswordReset(ctx, email); err != nil {
+			logrus.Errorf("password reset failed: %s", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// sendPasswordReset emails the link to reset the password, unknown emails are skipped.
+func (s *Users) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrAccountNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.resetRepo.Create(ctx, domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.appURL, url.QueryEscape(token))
+
+	if err := s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nto set a new password follow the link:\n%s\n\n"+
+			"The link is valid for an hour. If you haven't asked for it, just ignore this email.\n", user.Name, link),
+	}); err != nil {
+		return fmt.Errorf("sending email to user %d: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with the emailed token and signs the user out of every session.
+func (s *Users) ResetPassword(ctx context.Context, inp domain.ResetPasswordInput) error {
+	token, err := s.resetRepo.Consume(ctx, hashToken(inp.Token))
+	if err != nil {
+		return err
+	}
+
+	password, err := s.hasher.Hash(inp.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, token.UserID, password); err != nil {
+		return err
+	}
+
+	return s.revokeSessions(ctx, token.UserID, "")
+}
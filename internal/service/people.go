@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type PeopleRepository interface {
+	Create(ctx context.Context, inp domain.PersonInput) (domain.Person, error)
+	Get(ctx context.Context, id int64) (domain.Person, error)
+	List(ctx context.Context, inp domain.ListPeopleInput) ([]domain.Person, int, error)
+	Update(ctx context.Context, id int64, inp domain.PersonInput) (domain.Person, error)
+	Delete(ctx context.Context, id int64) error
+	Movies(ctx context.Context, id int64) ([]domain.PersonMovie, error)
+}
+
+// People manages the actors and other people credited in movies.
+type People struct {
+	peopleRepository PeopleRepository
+}
+
+func NewPeople(peopleRepository PeopleRepository) *People {
+	return &People{peopleRepository: peopleRepository}
+}
+
+func (p People) List(ctx context.Context, inp domain.ListPeopleInput) ([]domain.Person, int, error) {
+	return p.peopleRepository.List(ctx, inp.WithDefaults())
+}
+
+func (p People) Get(ctx context.Context, id int64) (domain.Person, error) {
+	return p.peopleRepository.Get(ctx, id)
+}
+
+func (p People) Create(ctx context.Context, inp domain.PersonInput) (domain.Person, error) {
+	return p.peopleRepository.Create(ctx, inp)
+}
+
+func (p People) Update(ctx context.Context, id int64, inp domain.PersonInput) (domain.Person, error) {
+	return p.peopleRepository.Update(ctx, id, inp)
+}
+
+// Delete removes the person together with their roles in movies.
+func (p People) Delete(ctx context.Context, id int64) error {
+	return p.peopleRepository.Delete(ctx, id)
+}
+
+// Movies returns the filmography of the person, the latest movies first.
+func (p People) Movies(ctx context.Context, id int64) ([]domain.PersonMovie, error) {
+	// an empty filmography doesn't tell a person without movies from a missing one
+	if _, err := p.peopleRepository.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return p.peopleRepository.Movies(ctx, id)
+}
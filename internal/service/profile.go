@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *Users) Profile(ctx context.Context, userID int64) (domain.User, error) {
+	return s.repo.GetByID(ctx, userID)
+}
+
+func (s *Users) UpdateProfile(ctx context.Context, userID int64, inp domain.UpdateProfileInput) (domain.User, error) {
+	user, err := s.Profile(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if inp.Name != nil {
+		user.Name = *inp.Name
+	}
+
+	if inp.Email != nil {
+		user.Email = *inp.Email
+	}
+
+	user, err = s.repo.Update(ctx, user)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	// a changed email is no longer verified
+	if user.VerifiedAt == nil {
+		if err := s.sendVerification(ctx, user); err != nil {
+			logrus.WithField("user_id", user.ID).Errorf("sending verification email failed: %s", err.Error())
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the password after checking the current one. The user stays
+// signed in on the current device only, every other session is revoked.
+func (s *Users) ChangePassword(ctx context.Context, principal domain.Principal, inp domain.ChangePasswordInput) error {
+	user, err := s.Profile(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.hasher.Verify(inp.CurrentPassword, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrWrongPassword
+	}
+
+	password, err := s.hasher.Hash(inp.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, password); err != nil {
+		return err
+	}
+
+	return s.revokeSessions(ctx, user.ID, principal.SessionID)
+}
+
+// DeleteAccount removes the user together with the sessions and revokes the access tokens in use.
+func (s *Users) DeleteAccount(ctx context.Context, principal domain.Principal) error {
+	if err := s.revokeSessions(ctx, principal.UserID, ""); err != nil {
+		return err
+	}
+
+	if err := s.revocations.RevokeToken(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(ctx, principal.UserID)
+}
+
+// revokeSessions signs the user out of every session except the kept one.
+func (s *Users) revokeSessions(ctx context.Context, userID int64, keepSessionID string) error {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.FamilyID == keepSessionID {
+			continue
+		}
+
+		if _, err := s.sessionRepo.DeleteSession(ctx, userID, session.FamilyID); err != nil {
+			return err
+		}
+
+		if err := s.revocations.RevokeSession(ctx, session.FamilyID, time.Now().Add(s.tokenTtl)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type ReviewsRepository interface {
+	Create(ctx context.Context, movieID, userID int64, inp domain.ReviewInput) (domain.Review, error)
+	Get(ctx context.Context, id int64) (domain.Review, error)
+	List(ctx context.Context, filter domain.ReviewFilter, inp domain.ListReviewsInput) ([]domain.Review, int, error)
+	Update(ctx context.Context, id int64, inp domain.ReviewInput) (domain.Review, error)
+	Moderate(ctx context.Context, id, moderatorID int64, inp domain.ModerateReviewInput) (domain.Review, error)
+	Delete(ctx context.Context, id int64) error
+	Vote(ctx context.Context, id, userID int64, helpful bool) error
+	DeleteVote(ctx context.Context, id, userID int64) error
+}
+
+// Reviews manages the reviews users write about movies. Reviews that aren't approved
+// are seen only by their authors and the editors, to everybody else they don't exist.
+type Reviews struct {
+	reviewsRepository ReviewsRepository
+}
+
+func NewReviews(reviewsRepository ReviewsRepository) *Reviews {
+	return &Reviews{reviewsRepository: reviewsRepository}
+}
+
+// ListByMovie returns the approved reviews of the movie, the newest first. Editors may list the other states too.
+func (r Reviews) ListByMovie(ctx context.Context, caller domain.Principal, movieID int64, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+	filter := domain.ReviewFilter{MovieID: movieID, Status: domain.ReviewApproved}
+	if caller.CanModerate() && inp.Status != "" {
+		filter.Status = inp.Status
+	}
+
+	return r.list(ctx, caller, filter, inp)
+}
+
+// ListByUser returns the reviews of the user, the newest first. The user and the editors
+// see the reviews in every state, the others only the approved ones.
+func (r Reviews) ListByUser(ctx context.Context, caller domain.Principal, userID int64, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+	filter := domain.ReviewFilter{UserID: userID, Status: domain.ReviewApproved}
+	if caller.UserID == userID || caller.CanModerate() {
+		filter.Status = inp.Status
+	}
+
+	return r.list(ctx, caller, filter, inp)
+}
+
+// list returns a page of the reviews the filter selects, without the notes the caller may not see.
+func (r Reviews) list(ctx context.Context, caller domain.Principal, filter domain.ReviewFilter, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+	reviews, total, err := r.reviewsRepository.List(ctx, filter, inp.WithDefaults())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range reviews {
+		reviews[i] = redact(caller, reviews[i])
+	}
+
+	return reviews, total, nil
+}
+
+// ListForModeration returns the reviews in the state, the pending ones by default, the oldest first.
+func (r Reviews) ListForModeration(ctx context.Context, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+	filter := domain.ReviewFilter{Status: inp.Status, OldestFirst: true}
+	if filter.Status == "" {
+		filter.Status = domain.ReviewPending
+	}
+
+	return r.reviewsRepository.List(ctx, filter, inp.WithDefaults())
+}
+
+func (r Reviews) Get(ctx context.Context, caller domain.Principal, id int64) (domain.Review, error) {
+	review, err := r.reviewsRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if !canSee(caller, review) {
+		return domain.Review{}, domain.ErrReviewNotFound
+	}
+
+	return redact(caller, review), nil
+}
+
+// Create posts the review of the caller, which waits for moderation.
+func (r Reviews) Create(ctx context.Context, caller domain.Principal, movieID int64, inp domain.ReviewInput) (domain.Review, error) {
+	return r.reviewsRepository.Create(ctx, movieID, caller.UserID, inp)
+}
+
+// Update changes the text of the review of the caller, the changed review is moderated again.
+func (r Reviews) Update(ctx context.Context, caller domain.Principal, id int64, inp domain.ReviewInput) (domain.Review, error) {
+	review, err := r.Get(ctx, caller, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if review.Author.ID != caller.UserID {
+		return domain.Review{}, domain.ErrNotReviewAuthor
+	}
+
+	return r.reviewsRepository.Update(ctx, id, inp)
+}
+
+// Delete removes the review, authors delete their own reviews and editors any review.
+func (r Reviews) Delete(ctx context.Context, caller domain.Principal, id int64) error {
+	review, err := r.Get(ctx, caller, id)
+	if err != nil {
+		return err
+	}
+
+	if review.Author.ID != caller.UserID && !caller.CanModerate() {
+		return domain.ErrNotReviewAuthor
+	}
+
+	return r.reviewsRepository.Delete(ctx, id)
+}
+
+// Moderate approves or rejects the review of another user.
+func (r Reviews) Moderate(ctx context.Context, caller domain.Principal, id int64, inp domain.ModerateReviewInput) (domain.Review, error) {
+	review, err := r.reviewsRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if review.Author.ID == caller.UserID {
+		return domain.Review{}, domain.ErrOwnReviewModerated
+	}
+
+	return r.reviewsRepository.Moderate(ctx, id, caller.UserID, inp)
+}
+
+// Vote saves whether the approved review was helpful to the caller and returns the review with the new counts.
+func (r Reviews) Vote(ctx context.Context, caller domain.Principal, id int64, inp domain.ReviewVoteInput) (domain.Review, error) {
+	review, err := r.votable(ctx, caller, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if err := r.reviewsRepository.Vote(ctx, review.ID, caller.UserID, *inp.Helpful); err != nil {
+		return domain.Review{}, err
+	}
+
+	review, err = r.reviewsRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	return redact(caller, review), nil
+}
+
+// DeleteVote withdraws the vote of the caller.
+func (r Reviews) DeleteVote(ctx context.Context, caller domain.Principal, id int64) error {
+	if _, err := r.votable(ctx, caller, id); err != nil {
+		return err
+	}
+
+	return r.reviewsRepository.DeleteVote(ctx, id, caller.UserID)
+}
+
+// votable returns the review if the caller may vote for it: only approved reviews
+// of other users are voted for.
+func (r Reviews) votable(ctx context.Context, caller domain.Principal, id int64) (domain.Review, error) {
+	review, err := r.reviewsRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	if review.Status != domain.ReviewApproved {
+		return domain.Review{}, domain.ErrReviewNotFound
+	}
+
+	if review.Author.ID == caller.UserID {
+		return domain.Review{}, domain.ErrOwnReviewVote
+	}
+
+	return review, nil
+}
+
+func canSee(caller domain.Principal, review domain.Review) bool {
+	return review.Status == domain.ReviewApproved || review.Author.ID == caller.UserID || caller.CanModerate()
+}
+
+// redact hides the moderation note from everybody but the author and the editors.
+func redact(caller domain.Principal, review domain.Review) domain.Review {
+	if review.Author.ID != caller.UserID && !caller.CanModerate() {
+		review.ModerationNote = ""
+	}
+
+	return review
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/totp"
+)
+
+const (
+	mfaChallengeTTL    = time.Minute * 5
+	recoveryCodesCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTwoFactor generates a new secret for the authenticator app. Two-factor authentication
+// is enabled only after the user confirms it with a code.
+func (s *Users) EnrollTwoFactor(ctx context.Context, userID int64) (domain.TwoFactorEnrollment, error) {
+	user, err := s.Profile(ctx, userID)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+
+	encrypted, err := s.secrets.Encrypt(secret)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+
+	enrolled, err := s.twoFactor.Enroll(ctx, user.ID, encrypted)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+
+	if !enrolled {
+		return domain.TwoFactorEnrollment{}, domain.ErrTwoFactorEnabled
+	}
+
+	return domain.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication with the first code from the authenticator app
+// and returns the recovery codes. The codes are stored hashed and can't be shown again.
+func (s *Users) ConfirmTwoFactor(ctx context.Context, userID int64, code string) (domain.RecoveryCodes, error) {
+	t, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		return domain.RecoveryCodes{}, err
+	}
+
+	if t.Enabled() {
+		return domain.RecoveryCodes{}, domain.ErrTwoFactorEnabled
+	}
+
+	step, ok, err := totp.Validate(t.Secret, normalizeCode(code), time.Now())
+	if err != nil {
+		return domain.RecoveryCodes{}, err
+	}
+
+	if !ok {
+		return domain.RecoveryCodes{}, domain.ErrInvalidCode
+	}
+
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return domain.RecoveryCodes{}, err
+		}
+
+		hashes[i] = hashToken(normalizeCode(codes[i]))
+	}
+
+	if err := s.twoFactor.Enable(ctx, userID, step, hashes); err != nil {
+		return domain.RecoveryCodes{}, err
+	}
+
+	return domain.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off. Both the password and a code are required.
+// Editors and admins can't turn it off, their role requires it.
+func (s *Users) DisableTwoFactor(ctx context.Context, userID int64, inp domain.DisableTwoFactorInput) error {
+	user, err := s.Profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Role.RequiresTwoFactor() {
+		return domain.ErrTwoFactorRequired
+	}
+
+	ok, err := s.hasher.Verify(inp.Password, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrWrongPassword
+	}
+
+	t, err := s.getTOTP(ctx, userID)
+	if err == nil && !t.Enabled() {
+		return domain.ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	ok, err = s.verifySecondFactor(ctx, t, inp.Code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrInvalidCode
+	}
+
+	return s.twoFactor.Delete(ctx, userID)
+}
+
+// mfaChallenge returns the MFA challenge token when the user has two-factor authentication
+// enabled, or an empty string when the first factor is enough.
+func (s *Users) mfaChallenge(ctx context.Context, userID int64) (string, error) {
+	enabled, err := twoFactorEnabled(ctx, s.twoFactor, userID)
+	if err != nil || !enabled {
+		return "", err
+	}
+
+	return s.signPurposeToken(userID, tokenUseMFAChallenge, "", mfaChallengeTTL)
+}
+
+// getTOTP returns the TOTP of the user with the secret decrypted. A secret stored before the secrets
+// were encrypted is encrypted on the way, like the legacy password hashes are rehashed on sign-in.
+func (s *Users) getTOTP(ctx context.Context, userID int64) (domain.TOTP, error) {
+	t, err := s.twoFactor.Get(ctx, userID)
+	if err != nil {
+		return domain.TOTP{}, err
+	}
+
+	if !s.secrets.Encrypted(t.Secret) {
+		encrypted, err := s.secrets.Encrypt(t.Secret)
+		if err != nil {
+			return domain.TOTP{}, err
+		}
+
+		if err := s.twoFactor.UpdateSecret(ctx, userID, encrypted); err != nil {
+			return domain.TOTP{}, err
+		}
+
+		return t, nil
+	}
+
+	if t.Secret, err = s.secrets.Decrypt(t.Secret); err != nil {
+		return domain.TOTP{}, err
+	}
+
+	return t, nil
+}
+
+// twoFactorEnabled reports whether the user has confirmed the two-factor authentication enrollment.
+func twoFactorEnabled(ctx context.Context, repo TwoFactorRepository, userID int64) (bool, error) {
+	t, err := repo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrTwoFactorNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return t.Enabled(), nil
+}
+
+// reauthenticate confirms the signed-in user before a sensitive change: the password is required,
+// and a code from the authenticator app or a recovery code when two-factor authentication is enabled.
+func (s *Users) reauthenticate(ctx context.Context, userID int64, password, code string) error {
+	user, err := s.Profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.hasher.Verify(password, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrWrongPassword
+	}
+
+	t, err := s.getTOTP(ctx, userID)
+	if errors.Is(err, domain.ErrTwoFactorNotEnrolled) || err == nil && !t.Enabled() {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if code == "" {
+		return domain.ErrInvalidCode
+	}
+
+	ok, err = s.verifySecondFactor(ctx, t, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrInvalidCode
+	}
+
+	return nil
+}
+
+// CompleteSignIn exchanges the MFA challenge token and a code from the authenticator app
+// or a recovery code for the token pair.
+func (s *Users) CompleteSignIn(ctx context.Context, inp domain.SignInMFAInput, client domain.ClientInfo) (string, string, error) {
+	claims, err := s.parsePurposeToken(ctx, inp.MFAToken, tokenUseMFAChallenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return "", "", domain.ErrInvalidToken
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrAccountNotFound) {
+		return "", "", domain.ErrInvalidToken
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.throttle.Check(ctx, user.Email, client.IP); err != nil {
+		return "", "", err
+	}
+
+	t, err := s.getTOTP(ctx, userID)
+	if errors.Is(err, domain.ErrTwoFactorNotEnrolled) || err == nil && !t.Enabled() {
+		return "", "", domain.ErrInvalidToken
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	ok, err := s.verifySecondFactor(ctx, t, inp.Code)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !ok {
+		if err := s.throttle.Failed(ctx, user.Email, client.IP); err != nil {
+			return "", "", err
+		}
+
+		return "", "", domain.ErrInvalidCode
+	}
+
+	if err := s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return "", "", err
+	}
+
+	if err := s.throttle.Succeeded(ctx, user.Email); err != nil {
+		return "", "", err
+	}
+
+	return s.generateTokens(ctx, user, client)
+}
+
+// verifySecondFactor accepts a code from the authenticator app or a recovery code. Either is accepted once.
+func (s *Users) verifySecondFactor(ctx context.Context, t domain.TOTP, code string) (bool, error) {
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		step, ok, err := totp.Validate(t.Secret, code, time.Now())
+		if err != nil || !ok {
+			return false, err
+		}
+
+		return s.twoFactor.UseStep(ctx, t.UserID, step)
+	}
+
+	return s.twoFactor.UseRecoveryCode(ctx, t.UserID, hashToken(code))
+}
+
+// newRecoveryCode returns a random code like "k3j5-x9qa-m2tz-w7rb".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeCode drops the separators users type or copy along with the codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/totp"
+)
+
+// memoryTwoFactor keeps the last used step and the recovery code hashes like the user_totp
+// and recovery_codes tables do.
+type memoryTwoFactor struct {
+	TwoFactorRepository
+	lastStep   int64
+	codeHashes map[string]bool
+}
+
+func (r *memoryTwoFactor) UseStep(_ context.Context, _ int64, step int64) (bool, error) {
+	if step <= r.lastStep {
+		return false, nil
+	}
+
+	r.lastStep = step
+	return true, nil
+}
+
+func (r *memoryTwoFactor) UseRecoveryCode(_ context.Context, _ int64, codeHash string) (bool, error) {
+	if !r.codeHashes[codeHash] {
+		return false, nil
+	}
+
+	delete(r.codeHashes, codeHash)
+	return true, nil
+}
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerifySecondFactor(t *testing.T) {
+	code := func(offset int64) string {
+		c, err := totp.Code(testSecret, totp.Step(time.Now())+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	const recoveryCode = "k3j5-x9qa-m2tz-w7rb"
+
+	tests := []struct {
+		name  string
+		codes []string
+		want  []bool
+	}{
+		{name: "authenticator code", codes: []string{code(0)}, want: []bool{true}},
+		{name: "authenticator code with a space", codes: []string{code(0)[:3] + " " + code(0)[3:]}, want: []bool{true}},
+		{name: "authenticator code replayed", codes: []string{code(0), code(0)}, want: []bool{true, false}},
+		{name: "older code after a newer one", codes: []string{code(1), code(-1)}, want: []bool{true, false}},
+		{name: "newer code after an older one", codes: []string{code(-1), code(0)}, want: []bool{true, true}},
+		{name: "recovery code", codes: []string{recoveryCode}, want: []bool{true}},
+		{name: "recovery code used twice", codes: []string{recoveryCode, recoveryCode}, want: []bool{true, false}},
+		{name: "recovery code typed differently", codes: []string{"K3J5 X9QA M2TZ W7RB", recoveryCode}, want: []bool{true, false}},
+		{name: "unknown recovery code", codes: []string{"aaaa-bbbb-cccc-dddd"}, want: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryTwoFactor{codeHashes: map[string]bool{hashToken(normalizeCode(recoveryCode)): true}}
+			s := &Users{twoFactor: repo}
+			secret := domain.TOTP{UserID: 1, Secret: testSecret}
+
+			for i, c := range tt.codes {
+				ok, err := s.verifySecondFactor(context.Background(), secret, c)
+				if err != nil {
+					t.Fatalf("code %d: %v", i, err)
+				}
+
+				if ok != tt.want[i] {
+					t.Errorf("code %d (%q) accepted = %t, want %t", i, c, ok, tt.want[i])
+				}
+			}
+		})
+	}
+}
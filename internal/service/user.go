@@ -2,25 +2,33 @@ package service
 
 import (
 	"context"
-	"database/sql"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"fmt"
-	"github.com/lukinairina90/crud_movies/internal/transport/rest"
-	"math/rand"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/mail"
+	"github.com/sirupsen/logrus"
 )
 
 type PasswordHasher interface {
 	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
 }
 
 type UsersRepository interface {
-	Create(ctx context.Context, user domain.User) error
-	GetByCredentials(ctx context.Context, email, password string) (domain.User, error)
+	Create(ctx context.Context, user domain.User) (domain.User, error)
+	GetByEmail(ctx context.Context, email string) (domain.User, error)
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	Delete(ctx context.Context, id int64) error
+	MarkVerified(ctx context.Context, id int64, email string) (bool, error)
 }
 
 //type InMemoryCache[K comparable, V any] interface {
@@ -30,24 +38,114 @@ type UsersRepository interface {
 type SessionRepository interface {
 	Create(ctx context.Context, token domain.RefreshSession) error
 	Get(ctx context.Context, token string) (domain.RefreshSession, error)
+	ListActive(ctx context.Context, userID int64) ([]domain.RefreshSession, error)
+	Rotate(ctx context.Context, id int64) (bool, error)
+	DeleteSession(ctx context.Context, userID int64, familyID string) (bool, error)
+	DeleteFamily(ctx context.Context, familyID string) error
+	DeleteByUser(ctx context.Context, userID int64) error
+}
+
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userID int64, issuedBefore, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, principal domain.Principal) (bool, error)
+}
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token domain.PasswordResetToken) error
+	Consume(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error)
+}
+
+type TwoFactorRepository interface {
+	Get(ctx context.Context, userID int64) (domain.TOTP, error)
+	Enroll(ctx context.Context, userID int64, secret string) (bool, error)
+	Enable(ctx context.Context, userID int64, step int64, codeHashes []string) error
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	UpdateSecret(ctx context.Context, userID int64, secret string) error
+	Delete(ctx context.Context, userID int64) error
+}
+
+// SecretCipher encrypts the secrets that have to be read back, like the TOTP secrets.
+type SecretCipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+	Encrypted(value string) bool
+}
+
+// SignInThrottle limits the failed sign-in attempts.
+type SignInThrottle interface {
+	Check(ctx context.Context, email, ip string) error
+	Failed(ctx context.Context, email, ip string) error
+	Succeeded(ctx context.Context, email string) error
+}
+
+// TokenSigner signs tokens and resolves the keys to verify them with.
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg mail.Message) error
+}
+
+// All the tokens are signed with the same keys, the token_use claim tells what a token is for.
+const (
+	tokenUseAccess            = "access"
+	tokenUseEmailVerification = "email_verification"
+	tokenUseMFAChallenge      = "mfa_challenge"
+)
+
+// accessClaims are the claims of an access token.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	TokenUse  string      `json:"token_use"`
+	Role      domain.Role `json:"role"`
+	TwoFactor bool        `json:"tfa,omitempty"`
+	SessionID string      `json:"sid"`
 }
 
 type Users struct {
 	repo        UsersRepository
 	sessionRepo SessionRepository
+	revocations RevocationRepository
+	resetRepo   PasswordResetRepository
+	twoFactor   TwoFactorRepository
+	secrets     SecretCipher
 	hasher      PasswordHasher
+	throttle    SignInThrottle
+	mailer      Mailer
 
-	hmacSecret []byte
-	tokenTtl   time.Duration
+	// resetThrottle limits the password reset requests, resetSlots the resets handled at once.
+	resetThrottle SignInThrottle
+	resetSlots    chan struct{}
+
+	signer   TokenSigner
+	tokenTtl time.Duration
+	appURL   string
+	issuer   string
 }
 
-func NewUsers(repo UsersRepository, sessionRepo SessionRepository, hasher PasswordHasher, hmacSecret []byte, tokenTtl time.Duration) *Users {
+func NewUsers(repo UsersRepository, sessionRepo SessionRepository, revocations RevocationRepository, resetRepo PasswordResetRepository, twoFactor TwoFactorRepository, secrets SecretCipher, hasher PasswordHasher, throttle SignInThrottle, resetThrottle SignInThrottle, mailer Mailer, signer TokenSigner, tokenTtl time.Duration, appURL, issuer string) *Users {
 	return &Users{
 		repo:        repo,
 		sessionRepo: sessionRepo,
+		revocations: revocations,
+		resetRepo:   resetRepo,
+		twoFactor:   twoFactor,
+		secrets:     secrets,
 		hasher:      hasher,
-		hmacSecret:  hmacSecret,
+		throttle:    throttle,
+		mailer:      mailer,
+		signer:      signer,
 		tokenTtl:    tokenTtl,
+		appURL:      appURL,
+		issuer:      issuer,
+
+		resetThrottle: resetThrottle,
+		resetSlots:    make(chan struct{}, passwordResetWorkers),
 	}
 }
 
@@ -57,77 +155,244 @@ func (s *Users) SignUp(ctx context.Context, inp domain.SignUpInput) error {
 		return err
 	}
 
-	user := domain.User{
+	user, err := s.repo.Create(ctx, domain.User{
 		Name:         inp.Name,
 		Email:        inp.Email,
 		Password:     password,
 		RegisteredAt: time.Now(),
+	})
+	if err != nil {
+		return err
 	}
 
-	return s.repo.Create(ctx, user)
+	// the account exists at this point, the user can ask for another email if this one is lost
+	if err := s.sendVerification(ctx, user); err != nil {
+		logrus.WithField("user_id", user.ID).Errorf("sending verification email failed: %s", err.Error())
+	}
+
+	return nil
 }
 
-func (s *Users) SignIn(ctx context.Context, inp domain.SignInInput) (string, string, error) {
-	password, err := s.hasher.Hash(inp.Password)
-	if err != nil {
-		return "", "", err
+// SignIn checks the credentials and issues the token pair. Users with two-factor authentication
+// enabled get an MFA challenge token instead, which is exchanged for the pair by CompleteSignIn.
+func (s *Users) SignIn(ctx context.Context, inp domain.SignInInput, client domain.ClientInfo) (domain.SignInResult, error) {
+	if err := s.throttle.Check(ctx, inp.Email, client.IP); err != nil {
+		return domain.SignInResult{}, err
 	}
 
-	user, err := s.repo.GetByCredentials(ctx, inp.Email, password)
+	user, err := s.repo.GetByEmail(ctx, inp.Email)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", "", domain.ErrUserNotFound
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			return domain.SignInResult{}, s.signInFailed(ctx, inp.Email, client.IP)
 		}
-		return "", "", err
+		return domain.SignInResult{}, err
+	}
+
+	ok, err := s.hasher.Verify(inp.Password, user.Password)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	if !ok {
+		return domain.SignInResult{}, s.signInFailed(ctx, inp.Email, client.IP)
 	}
 
-	return s.generateTokens(ctx, user.ID)
+	if user.VerifiedAt == nil {
+		return domain.SignInResult{}, domain.ErrEmailNotVerified
+	}
+
+	if s.hasher.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user.ID, inp.Password)
+	}
+
+	// the failed attempts are counted on until the second factor is passed too
+	mfaToken, err := s.mfaChallenge(ctx, user.ID)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	if mfaToken != "" {
+		return domain.SignInResult{MFAToken: mfaToken}, nil
+	}
+
+	if err := s.throttle.Succeeded(ctx, inp.Email); err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	accessToken, refreshToken, err := s.generateTokens(ctx, user, client)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	return domain.SignInResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
-func (s *Users) ParseToken(_ context.Context, token string) (int64, error) {
+// signInFailed counts the failed attempt and returns the error for the client.
+func (s *Users) signInFailed(ctx context.Context, email, ip string) error {
+	if err := s.throttle.Failed(ctx, email, ip); err != nil {
+		return err
+	}
 
-	fmt.Println(token)
+	return domain.ErrInvalidCredentials
+}
+
+// rehashPassword upgrades the stored hash to the current scheme and parameters.
+// The sign-in has already succeeded at this point, so a failure is only logged.
+func (s *Users) rehashPassword(ctx context.Context, userID int64, password string) {
+	hash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePassword(ctx, userID, hash)
+	}
 
-	t, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpecting signing method %v", token.Header["alg"])
-		}
-		return s.hmacSecret, nil
-	})
 	if err != nil {
-		return 0, err
+		logrus.WithField("user_id", userID).Errorf("password rehash failed: %s", err.Error())
 	}
+}
 
-	if !t.Valid {
-		return 0, errors.New("invalid token")
+func (s *Users) ParseToken(ctx context.Context, token string) (domain.Principal, error) {
+	var claims accessClaims
+	t, err := jwt.ParseWithClaims(token, &claims, s.signer.Keyfunc)
+	if err != nil || !t.Valid || claims.TokenUse != tokenUseAccess {
+		return domain.Principal{}, domain.ErrInvalidAccessToken
 	}
 
-	claims, ok := t.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, errors.New("invalid claims")
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return domain.Principal{}, domain.ErrInvalidAccessToken
 	}
 
-	subject, ok := claims["sub"].(string)
-	if !ok {
-		return 0, errors.New("invalid subject")
+	if claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return domain.Principal{}, domain.ErrInvalidAccessToken
+	}
+
+	principal := domain.Principal{
+		UserID:    int64(id),
+		Role:      claims.Role,
+		TwoFactor: claims.TwoFactor,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
 	}
 
-	id, err := strconv.Atoi(subject)
+	revoked, err := s.revocations.IsRevoked(ctx, principal)
 	if err != nil {
-		return 0, errors.New("invalid subject")
+		return domain.Principal{}, err
 	}
 
-	return int64(id), nil
+	if revoked {
+		return domain.Principal{}, domain.ErrTokenRevoked
+	}
+
+	return principal, nil
 }
 
-func (s *Users) generateTokens(ctx context.Context, userId int64) (string, string, error) {
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   strconv.Itoa(int(userId)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTtl)),
+// Logout ends the current session: the access token is revoked and the refresh tokens of the session are deleted.
+func (s *Users) Logout(ctx context.Context, principal domain.Principal) error {
+	if err := s.revocations.RevokeToken(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+		return err
+	}
+
+	if principal.SessionID == "" {
+		return nil
+	}
+
+	_, err := s.sessionRepo.DeleteSession(ctx, principal.UserID, principal.SessionID)
+
+	return err
+}
+
+// LogoutAll ends every session of the user: all refresh tokens are deleted and every
+// access token issued so far is revoked.
+func (s *Users) LogoutAll(ctx context.Context, principal domain.Principal) error {
+	if err := s.sessionRepo.DeleteByUser(ctx, principal.UserID); err != nil {
+		return err
+	}
+
+	// iat has a precision of seconds, so the tokens issued during the current second are revoked as well
+	issuedBefore := time.Now().Truncate(time.Second).Add(time.Second)
+
+	return s.revocations.RevokeUserTokens(ctx, principal.UserID, issuedBefore, time.Now().Add(s.tokenTtl))
+}
+
+// Sessions returns the signed-in devices of the user.
+func (s *Users) Sessions(ctx context.Context, principal domain.Principal) ([]domain.Session, error) {
+	tokens, err := s.sessionRepo.ListActive(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]domain.Session, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, domain.Session{
+			ID:         t.FamilyID,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    t.FamilyID == principal.SessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one device: its refresh tokens are deleted
+// and the access tokens issued within the session are revoked.
+func (s *Users) RevokeSession(ctx context.Context, principal domain.Principal, sessionID string) error {
+	found, err := s.sessionRepo.DeleteSession(ctx, principal.UserID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return domain.ErrSessionNotFound
+	}
+
+	return s.revocations.RevokeSession(ctx, sessionID, time.Now().Add(s.tokenTtl))
+}
+
+func (s *Users) generateTokens(ctx context.Context, user domain.User, client domain.ClientInfo) (string, string, error) {
+	familyID, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.issueTokens(ctx, user, domain.RefreshSession{
+		FamilyID:  familyID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
 	})
+}
 
-	accessToken, err := t.SignedString(s.hmacSecret)
+// issueTokens creates an access token and the next refresh token of the session. The access token
+// of an editor or an admin tells whether two-factor authentication is enabled, which the role requires.
+func (s *Users) issueTokens(ctx context.Context, user domain.User, session domain.RefreshSession) (string, string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+
+	var tfa bool
+	if user.Role.RequiresTwoFactor() {
+		if tfa, err = twoFactorEnabled(ctx, s.twoFactor, user.ID); err != nil {
+			return "", "", err
+		}
+	}
+
+	accessToken, err := s.signer.Sign(accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(int(user.ID)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTtl)),
+		},
+		TokenUse:  tokenUseAccess,
+		Role:      user.Role,
+		TwoFactor: tfa,
+		SessionID: session.FamilyID,
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -137,39 +402,96 @@ func (s *Users) generateTokens(ctx context.Context, userId int64) (string, strin
 		return "", "", err
 	}
 
-	if err := s.sessionRepo.Create(ctx, domain.RefreshSession{
-		UserID:    userId,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 30),
-	}); err != nil {
+	session.UserID = user.ID
+	session.Token = refreshToken
+	session.ExpiresAt = time.Now().Add(time.Hour * 24 * 30)
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
 		return "", "", err
 	}
 
 	return accessToken, refreshToken, nil
 }
 
-func newRefreshToken() (string, error) {
-	b := make([]byte, 32)
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
 
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
+	return hex.EncodeToString(b), nil
+}
 
-	if _, err := r.Read(b); err != nil {
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%x", b), nil
+	return hex.EncodeToString(b), nil
 }
 
-func (s *Users) RefreshTokens(ctx context.Context, refreshToken string) (string, string, error) {
+// hashToken hashes a random token for storage. The token is random enough to not need a slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Every refresh token can be
+// used only once: presenting a token that has already been rotated means it has leaked,
+// so the whole family is revoked and its holder, legitimate or not, has to sign in again.
+func (s *Users) RefreshTokens(ctx context.Context, refreshToken string, client domain.ClientInfo) (string, string, error) {
 	session, err := s.sessionRepo.Get(ctx, refreshToken)
 	if err != nil {
 		return "", "", err
 	}
 
+	if session.RotatedAt != nil {
+		return "", "", s.revokeReusedFamily(ctx, session)
+	}
+
 	if session.ExpiresAt.Unix() < time.Now().Unix() {
-		return "", "", rest.ErrRefreshTokenExpired
+		return "", "", domain.ErrRefreshTokenExpired
+	}
+
+	rotated, err := s.sessionRepo.Rotate(ctx, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// a concurrent request has rotated the token in the meantime
+	if !rotated {
+		return "", "", s.revokeReusedFamily(ctx, session)
+	}
+
+	// the role is read again, so that a role change takes effect on the next refresh
+	user, err := s.repo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.issueTokens(ctx, user, domain.RefreshSession{
+		FamilyID:  session.FamilyID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		CreatedAt: session.CreatedAt,
+	})
+}
+
+func (s *Users) revokeReusedFamily(ctx context.Context, session domain.RefreshSession) error {
+	logrus.WithFields(logrus.Fields{
+		"user_id":   session.UserID,
+		"family_id": session.FamilyID,
+	}).Warn("refresh token reuse detected, revoking the token family")
+
+	if err := s.sessionRepo.DeleteFamily(ctx, session.FamilyID); err != nil {
+		return err
+	}
+
+	if err := s.revocations.RevokeSession(ctx, session.FamilyID, time.Now().Add(s.tokenTtl)); err != nil {
+		return err
 	}
 
-	return s.generateTokens(ctx, session.UserID)
+	return domain.ErrRefreshTokenReused
 }
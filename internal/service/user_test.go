@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+// memorySessions keeps the refresh tokens like the refresh_tokens table does.
+type memorySessions struct {
+	SessionRepository
+	sessions map[string]*domain.RefreshSession
+	lastID   int64
+	// loseRace makes Rotate report the token as rotated by a concurrent request.
+	loseRace bool
+}
+
+func (r *memorySessions) Create(_ context.Context, session domain.RefreshSession) error {
+	r.lastID++
+	session.ID = r.lastID
+	r.sessions[session.Token] = &session
+	return nil
+}
+
+func (r *memorySessions) Get(_ context.Context, token string) (domain.RefreshSession, error) {
+	session, ok := r.sessions[token]
+	if !ok {
+		return domain.RefreshSession{}, domain.ErrInvalidRefreshToken
+	}
+	return *session, nil
+}
+
+func (r *memorySessions) Rotate(_ context.Context, id int64) (bool, error) {
+	for _, session := range r.sessions {
+		if session.ID != id {
+			continue
+		}
+		if session.RotatedAt != nil || r.loseRace {
+			return false, nil
+		}
+
+		now := time.Now()
+		session.RotatedAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *memorySessions) DeleteFamily(_ context.Context, familyID string) error {
+	for token, session := range r.sessions {
+		if session.FamilyID == familyID {
+			delete(r.sessions, token)
+		}
+	}
+	return nil
+}
+
+type memoryRevocations struct {
+	RevocationRepository
+	sessions []string
+}
+
+func (r *memoryRevocations) RevokeSession(_ context.Context, sessionID string, _ time.Time) error {
+	r.sessions = append(r.sessions, sessionID)
+	return nil
+}
+
+type memoryUsers struct {
+	UsersRepository
+}
+
+func (memoryUsers) GetByID(_ context.Context, id int64) (domain.User, error) {
+	return domain.User{ID: id, Role: domain.RoleViewer}, nil
+}
+
+type plainSigner struct {
+	TokenSigner
+}
+
+func (plainSigner) Sign(jwt.Claims) (string, error) {
+	return "access-token", nil
+}
+
+func TestRefreshTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		// use presents the refresh tokens of the signed in family and returns the error of the last one
+		use         func(s *Users, sessions *memorySessions, token string) error
+		wantErr     error
+		wantRevoked bool
+	}{
+		{
+			name: "first use",
+			use: func(s *Users, _ *memorySessions, token string) error {
+				_, _, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{})
+				return err
+			},
+		},
+		{
+			name: "rotated token used",
+			use: func(s *Users, _ *memorySessions, token string) error {
+				_, next, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{})
+				if err != nil {
+					return err
+				}
+				_, _, err = s.RefreshTokens(context.Background(), next, domain.ClientInfo{})
+				return err
+			},
+		},
+		{
+			name: "rotated token reused",
+			use: func(s *Users, _ *memorySessions, token string) error {
+				if _, _, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{}); err != nil {
+					return err
+				}
+				_, _, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{})
+				return err
+			},
+			wantErr:     domain.ErrRefreshTokenReused,
+			wantRevoked: true,
+		},
+		{
+			name: "token rotated by a concurrent request",
+			use: func(s *Users, sessions *memorySessions, token string) error {
+				sessions.loseRace = true
+				_, _, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{})
+				return err
+			},
+			wantErr:     domain.ErrRefreshTokenReused,
+			wantRevoked: true,
+		},
+		{
+			name: "expired token",
+			use: func(s *Users, sessions *memorySessions, token string) error {
+				sessions.sessions[token].ExpiresAt = time.Now().Add(-time.Minute)
+				_, _, err := s.RefreshTokens(context.Background(), token, domain.ClientInfo{})
+				return err
+			},
+			wantErr: domain.ErrRefreshTokenExpired,
+		},
+		{
+			name: "unknown token",
+			use: func(s *Users, _ *memorySessions, _ string) error {
+				_, _, err := s.RefreshTokens(context.Background(), "unknown", domain.ClientInfo{})
+				return err
+			},
+			wantErr: domain.ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &memorySessions{sessions: make(map[string]*domain.RefreshSession)}
+			revocations := &memoryRevocations{}
+			s := &Users{
+				repo:        memoryUsers{},
+				sessionRepo: sessions,
+				revocations: revocations,
+				signer:      plainSigner{},
+				tokenTtl:    time.Minute,
+			}
+
+			_, token, err := s.generateTokens(context.Background(), domain.User{ID: 1}, domain.ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			familyID := sessions.sessions[token].FamilyID
+
+			err = tt.use(s, sessions, token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshTokens error = %v, want %v", err, tt.wantErr)
+			}
+
+			if revoked := len(revocations.sessions) > 0; revoked != tt.wantRevoked {
+				t.Errorf("session revoked = %t, want %t", revoked, tt.wantRevoked)
+			}
+
+			if !tt.wantRevoked {
+				return
+			}
+
+			// none of the tokens of the family works any longer, the legitimate one included
+			if len(sessions.sessions) != 0 {
+				t.Errorf("%d refresh tokens of the family left", len(sessions.sessions))
+			}
+			if revocations.sessions[0] != familyID {
+				t.Errorf("revoked session %q, want %q", revocations.sessions[0], familyID)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+	"github.com/lukinairina90/crud_movies/pkg/mail"
+)
+
+const verificationTokenTTL = time.Hour * 24
+
+// purposeClaims are the claims of the single-purpose tokens handed to users, e.g. by email.
+type purposeClaims struct {
+	jwt.RegisteredClaims
+	TokenUse string `json:"token_use"`
+	Email    string `json:"email,omitempty"`
+}
+
+// VerifyEmail confirms the email the verification token was sent to. The token can be used only
+// once and only while the user still has the same email.
+func (s *Users) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.parsePurposeToken(ctx, token, tokenUseEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	verified, err := s.repo.MarkVerified(ctx, id, claims.Email)
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return domain.ErrInvalidToken
+	}
+
+	return s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// ResendVerification sends another verification email. Whether the email belongs to an
+// unverified user is not revealed, unknown and verified emails are silently skipped.
+func (s *Users) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrAccountNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+func (s *Users) sendVerification(ctx context.Context, user domain.User) error {
+	token, err := s.signPurposeToken(user.ID, tokenUseEmailVerification, user.Email, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.appURL, url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email by following the link:\n%s\n\n"+
+			"The link is valid for 24 hours. If you haven't signed up, just ignore this email.\n", user.Name, link),
+	})
+}
+
+func (s *Users) signPurposeToken(userID int64, tokenUse, email string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	return s.signer.Sign(purposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+		TokenUse: tokenUse,
+		Email:    email,
+	})
+}
+
+// parsePurposeToken checks the signature, expiry and purpose of the token and that it hasn't been used yet.
+func (s *Users) parsePurposeToken(ctx context.Context, token, tokenUse string) (purposeClaims, error) {
+	var claims purposeClaims
+	t, err := jwt.ParseWithClaims(token, &claims, s.signer.Keyfunc)
+	if err != nil || !t.Valid || claims.TokenUse != tokenUse || claims.ID == "" || claims.ExpiresAt == nil {
+		return purposeClaims{}, domain.ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.IsRevoked(ctx, domain.Principal{TokenID: claims.ID, IssuedAt: claims.IssuedAt.Time})
+	if err != nil {
+		return purposeClaims{}, err
+	}
+
+	if revoked {
+		return purposeClaims{}, domain.ErrInvalidToken
+	}
+
+	return claims, nil
+}
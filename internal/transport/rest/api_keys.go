@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, userID int64, inp domain.CreateAPIKeyInput) (domain.CreatedAPIKey, error)
+	List(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+// APIKeys manages the API keys of the authenticated user.
+type APIKeys struct {
+	apiKeyService APIKeyService
+}
+
+func NewAPIKeys(apiKeyService APIKeyService) *APIKeys {
+	return &APIKeys{apiKeyService: apiKeyService}
+}
+
+func (k *APIKeys) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	keys := r.Group("/me/api-keys").Use(middlewares...)
+	{
+		keys.GET("", k.getAPIKeys)
+		keys.POST("", k.createAPIKey)
+		keys.DELETE("/:id", k.deleteAPIKey)
+	}
+}
+
+// @Summary Get API Keys
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description lists the api keys of the current user
+// @ID get-api-keys
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} domain.APIKey
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/api-keys [get]
+func (k *APIKeys) getAPIKeys(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	keys, err := k.apiKeyService.List(ctx, principal.UserID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// @Summary Create API Key
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description creates an api key, the key is returned only once. Send it as "Authorization: ApiKey <key>"
+// @ID create-api-key
+// @Accept  json
+// @Produce  json
+// @Param input body domain.CreateAPIKeyInput true "name, scopes and expiry"
+// @Success 201 {object} domain.CreatedAPIKey
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/api-keys [post]
+func (k *APIKeys) createAPIKey(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.CreateAPIKeyInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	key, err := k.apiKeyService.Create(ctx, principal.UserID, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, key)
+}
+
+// @Summary Delete API Key
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description revokes the api key
+// @ID delete-api-key
+// @Accept  json
+// @Produce  json
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/api-keys/{id} [delete]
+func (k *APIKeys) deleteAPIKey(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	if err := k.apiKeyService.Delete(ctx, principal.UserID, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
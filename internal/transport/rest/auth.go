@@ -6,24 +6,36 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"github.com/lukinairina90/crud_movies/internal/domain"
-	"github.com/sirupsen/logrus"
 )
 
 type UserService interface {
 	SignUp(ctx context.Context, inp domain.SignUpInput) error
-	SignIn(ctx context.Context, inp domain.SignInInput) (string, string, error)
-	ParseToken(ctx context.Context, token string) (int64, error)
-	RefreshTokens(ctx context.Context, refreshToken string) (string, string, error)
+	SignIn(ctx context.Context, inp domain.SignInInput, client domain.ClientInfo) (domain.SignInResult, error)
+	CompleteSignIn(ctx context.Context, inp domain.SignInMFAInput, client domain.ClientInfo) (string, string, error)
+	ParseToken(ctx context.Context, token string) (domain.Principal, error)
+	RefreshTokens(ctx context.Context, refreshToken string, client domain.ClientInfo) (string, string, error)
+	Logout(ctx context.Context, principal domain.Principal) error
+	LogoutAll(ctx context.Context, principal domain.Principal) error
+	Sessions(ctx context.Context, principal domain.Principal) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, principal domain.Principal, sessionID string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string, client domain.ClientInfo) error
+	ResetPassword(ctx context.Context, inp domain.ResetPasswordInput) error
+}
+
+type APIKeyParser interface {
+	ParseAPIKey(ctx context.Context, key string) (domain.Principal, error)
 }
 
 type Auth struct {
-	userService UserService
+	userService   UserService
+	apiKeyService APIKeyParser
 }
 
-func NewAuth(userService UserService) *Auth {
-	return &Auth{userService: userService}
+func NewAuth(userService UserService, apiKeyService APIKeyParser) *Auth {
+	return &Auth{userService: userService, apiKeyService: apiKeyService}
 }
 
 func (a *Auth) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
@@ -31,7 +43,16 @@ func (a *Auth) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
 	{
 		auth.POST("/sign-up", a.signUp)
 		auth.POST("/sign-in", a.signIn)
+		auth.POST("/sign-in/mfa", a.signInMFA)
 		auth.GET("/refresh", a.refresh)
+		auth.POST("/verify", a.verifyEmail)
+		auth.POST("/resend-verification", a.resendVerification)
+		auth.POST("/forgot-password", a.forgotPassword)
+		auth.POST("/reset-password", a.resetPassword)
+		auth.POST("/logout", a.AuthMiddleware(), RequireSession(), a.logout)
+		auth.POST("/logout-all", a.AuthMiddleware(), RequireSession(), a.logoutAll)
+		auth.GET("/sessions", a.AuthMiddleware(), RequireSession(), a.getSessions)
+		auth.DELETE("/sessions/:id", a.AuthMiddleware(), RequireSession(), a.deleteSession)
 	}
 }
 
@@ -43,35 +64,27 @@ func (a *Auth) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
 // @Produce  json
 // @Param input body domain.SignUpInput true "account info"
 // @Success 200 {object} domain.SignUpInput
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} BadRequestErr
-// @Failure default {object} BadRequestErr
+// @Failure 400,404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /auth/sign-up [post]
 func (a *Auth) signUp(ctx *gin.Context) {
 	var inp domain.SignUpInput
 
-	if err := ctx.BindJSON(&inp); err != nil {
-		logError("signUp", err)
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("cannot parse body user", nil))
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	if err := inp.Validate(); err != nil {
-		vErrs := err.(validator.ValidationErrors)
-		errs := make(map[string]string)
-		for _, fErr := range vErrs {
-			errs[fErr.ActualTag()] = fErr.Error()
-		}
-
-		logError("signUp", err)
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("validation error", errs))
+		ctx.Error(err)
 		return
 	}
 
 	err := a.userService.SignUp(ctx, inp)
 	if err != nil {
-		logError("signUp", err)
-		ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("userService.SignUp error"))
+		ctx.Error(err)
 		return
 	}
 
@@ -80,43 +93,81 @@ func (a *Auth) signUp(ctx *gin.Context) {
 
 // @Summary SignIn
 // @Tags auth
-// @Description login
+// @Description login, returns an mfa_token to complete the sign-in with when two-factor authentication is enabled
 // @ID login
 // @Accept  json
 // @Produce  json
 // @Param input body domain.SignInInput true "credentials"
 // @Success 200 {string} string "token"
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} BadRequestErr
-// @Failure default {object} BadRequestErr
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 429 {object} Problem
+// @Header 429 {integer} Retry-After "seconds until the next attempt is allowed"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /auth/sign-in [post]
 func (a *Auth) signIn(ctx *gin.Context) {
 	var inp domain.SignInInput
-	if err := ctx.BindJSON(&inp); err != nil {
-		logError("signIn", err)
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("connot parse body user", nil))
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
 	}
 
 	if err := inp.Validate(); err != nil {
-		vErrs := err.(validator.ValidationErrors)
-		errs := make(map[string]string)
-		for _, fErr := range vErrs {
-			errs[fErr.ActualTag()] = fErr.Error()
-		}
-
-		logError("signIn", err)
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("validation error", errs))
+		ctx.Error(err)
+		return
 	}
 
-	accessToken, refreshToken, err := a.userService.SignIn(ctx, inp)
+	res, err := a.userService.SignIn(ctx, inp, clientInfo(ctx))
 	if err != nil {
-		if errors.Is(err, domain.ErrUserNotFound) {
-			HandleNotFoundError(ctx, err)
-			return
-		}
+		ctx.Error(err)
+		return
+	}
 
-		logError("signIn", err)
-		ctx.JSON(http.StatusInternalServerError, NewBadRequestErr("userService.SignUp error", nil))
+	if res.MFAToken != "" {
+		ctx.JSON(http.StatusOK, map[string]string{
+			"mfa_token": res.MFAToken,
+		})
+		return
+	}
+
+	ctx.SetCookie("refresh-token", res.RefreshToken, 3600, "/auth", "localhost", false, true)
+
+	ctx.JSON(http.StatusOK, map[string]string{
+		"token": res.AccessToken,
+	})
+}
+
+// @Summary SignIn MFA
+// @Tags auth
+// @Description completes the sign-in with a code from the authenticator app or a recovery code
+// @ID login-mfa
+// @Accept  json
+// @Produce  json
+// @Param input body domain.SignInMFAInput true "challenge token and code"
+// @Success 200 {string} string "token"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 429 {object} Problem
+// @Header 429 {integer} Retry-After "seconds until the next attempt is allowed"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/sign-in/mfa [post]
+func (a *Auth) signInMFA(ctx *gin.Context) {
+	var inp domain.SignInMFAInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	accessToken, refreshToken, err := a.userService.CompleteSignIn(ctx, inp, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -135,23 +186,26 @@ func (a *Auth) signIn(ctx *gin.Context) {
 // @Produce  json
 // @Header 200 {string} Token "token"
 // @Success 200 {string} string
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} BadRequestErr
-// @Failure default {object} BadRequestErr
+// @Failure 400,404 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /auth/refresh [get]
 func (a *Auth) refresh(ctx *gin.Context) {
 	cookie, err := ctx.Cookie("refresh-token")
 	if err != nil {
-		logError("refresh", err)
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("get cookie from request error", nil))
+		ctx.Error(domain.ErrInvalidRefreshToken)
 		return
 	}
-	logrus.Info("%s", cookie)
 
-	accessToken, refreshToken, err := a.userService.RefreshTokens(ctx, cookie)
+	accessToken, refreshToken, err := a.userService.RefreshTokens(ctx, cookie, clientInfo(ctx))
 	if err != nil {
-		logError("SignIn | refresh", err)
-		ctx.JSON(http.StatusInternalServerError, NewBadRequestErr("refresh token error", nil))
+		if errors.Is(err, domain.ErrRefreshTokenReused) || errors.Is(err, domain.ErrRefreshTokenExpired) ||
+			errors.Is(err, domain.ErrInvalidRefreshToken) {
+			ctx.SetCookie("refresh-token", "", -1, "/auth", "localhost", false, true)
+		}
+
+		ctx.Error(err)
 		return
 	}
 
@@ -161,3 +215,245 @@ func (a *Auth) refresh(ctx *gin.Context) {
 		"token": accessToken,
 	})
 }
+
+// @Summary Verify Email
+// @Tags auth
+// @Description confirms the email with the token from the verification email
+// @ID verify-email
+// @Accept  json
+// @Produce  json
+// @Param input body domain.VerifyEmailInput true "verification token"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/verify [post]
+func (a *Auth) verifyEmail(ctx *gin.Context) {
+	var inp domain.VerifyEmailInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := a.userService.VerifyEmail(ctx, inp.Token); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Resend Verification
+// @Tags auth
+// @Description sends another verification email, responds the same whether the email is registered or not
+// @ID resend-verification
+// @Accept  json
+// @Produce  json
+// @Param input body domain.ResendVerificationInput true "email"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/resend-verification [post]
+func (a *Auth) resendVerification(ctx *gin.Context) {
+	var inp domain.ResendVerificationInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := a.userService.ResendVerification(ctx, inp.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Forgot Password
+// @Tags auth
+// @Description emails a link to reset the password, responds the same whether the email is registered or not
+// @ID forgot-password
+// @Accept  json
+// @Produce  json
+// @Param input body domain.ForgotPasswordInput true "email"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 429 {object} Problem
+// @Header 429 {integer} Retry-After "seconds until the next request is allowed"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/forgot-password [post]
+func (a *Auth) forgotPassword(ctx *gin.Context) {
+	var inp domain.ForgotPasswordInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := a.userService.ForgotPassword(ctx, inp.Email, clientInfo(ctx)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Reset Password
+// @Tags auth
+// @Description sets a new password with the token from the email and signs the user out of every session
+// @ID reset-password
+// @Accept  json
+// @Produce  json
+// @Param input body domain.ResetPasswordInput true "reset token and new password"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/reset-password [post]
+func (a *Auth) resetPassword(ctx *gin.Context) {
+	var inp domain.ResetPasswordInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := a.userService.ResetPassword(ctx, inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Logout
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revokes the access and refresh tokens of the current session
+// @ID logout
+// @Accept  json
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/logout [post]
+func (a *Auth) logout(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	if err := a.userService.Logout(ctx, principal); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetCookie("refresh-token", "", -1, "/auth", "localhost", false, true)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Logout everywhere
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revokes every access and refresh token of the user
+// @ID logout-all
+// @Accept  json
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/logout-all [post]
+func (a *Auth) logoutAll(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	if err := a.userService.LogoutAll(ctx, principal); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetCookie("refresh-token", "", -1, "/auth", "localhost", false, true)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Sessions
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description returns the devices the user is signed in on
+// @ID get-sessions
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} domain.Session
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/sessions [get]
+func (a *Auth) getSessions(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	sessions, err := a.userService.Sessions(ctx, principal)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Revoke Session
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description signs the user out of a device
+// @ID delete-session
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/sessions/{id} [delete]
+func (a *Auth) deleteSession(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	if err := a.userService.RevokeSession(ctx, principal, ctx.Param("id")); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// clientInfo describes the client of the request for the session records.
+func clientInfo(ctx *gin.Context) domain.ClientInfo {
+	userAgent := []rune(ctx.Request.UserAgent())
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	return domain.ClientInfo{
+		UserAgent: string(userAgent),
+		IP:        ctx.ClientIP(),
+	}
+}
@@ -2,58 +2,119 @@ package rest
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
 )
 
+const problemContentType = "application/problem+json"
+
 var (
-	ErrMovieNotFound       = errors.New("book not found")
-	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	errEmptyAuthHeader   = domain.NewUnauthorizedError("missing_credentials", "empty auth header")
+	errInvalidAuthHeader = domain.NewUnauthorizedError("invalid_auth_header", "invalid auth header")
+	errEmptyToken        = domain.NewUnauthorizedError("missing_credentials", "token is empty")
+	errInvalidID         = domain.NewValidationError("validation_failed", "validation error", map[string]string{"id": "should be an integer"})
+	errUnsupportedPatch  = &domain.Error{Kind: domain.KindUnsupportedMediaType, Code: "unsupported_patch_format",
+		Message: "patch documents have to be " + mergePatchContentType + " or " + jsonPatchContentType}
 )
 
-type NotFoundErr struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// Problem is the RFC 7807 body of every error response. Code is a stable identifier of the error
+// for clients to switch on, Fields lists the invalid fields of validation errors and Existing
+// links the record a conflicting one duplicates.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Existing string            `json:"existing,omitempty"`
 }
 
-func NewNotFoundErr(message string) NotFoundErr {
-	return NotFoundErr{Code: http.StatusNotFound, Message: message}
-}
+// ErrorMiddleware renders the error a handler has reported with ctx.Error as a problem response.
+// Domain errors are shown to the client, any other error is logged and hidden behind a 500.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
 
-type BadRequestErr struct {
-	Code    int               `json:"code"`
-	Message string            `json:"message"`
-	Fields  map[string]string `json:"fields,omitempty"`
-}
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
 
-func NewBadRequestErr(mgs string, fields map[string]string) BadRequestErr {
-	return BadRequestErr{Code: http.StatusBadRequest, Message: mgs, Fields: fields}
-}
+		err := c.Errors.Last().Err
+		problem := Problem{
+			Type:     "about:blank",
+			Instance: c.Request.URL.Path,
+		}
 
-type InternalServerErr struct {
-	Code    int    `json:"code"`
-	Message string `json:"error"`
-}
+		var dErr *domain.Error
+		if errors.As(err, &dErr) {
+			problem.Status = errorStatus(dErr.Kind)
+			problem.Detail = dErr.Message
+			problem.Code = dErr.Code
+			problem.Fields = dErr.Fields
+		} else {
+			logError(c.Request.Method+" "+c.FullPath(), err)
+			problem.Status = http.StatusInternalServerError
+			problem.Code = "internal_error"
+		}
+		problem.Title = http.StatusText(problem.Status)
+
+		var throttled *domain.TooManyAttemptsError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		}
 
-func NewInternalServerErr(message string) InternalServerErr {
-	return InternalServerErr{Code: http.StatusInternalServerError, Message: message}
+		var duplicate *domain.DuplicateMovieError
+		if errors.As(err, &duplicate) {
+			problem.Existing = fmt.Sprintf("/movies/%d", duplicate.ExistingID)
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.JSON(problem.Status, problem)
+	}
 }
 
-type UnauthorizedErr struct {
-	Code    int    `json:"code"`
-	Message string `json:"error"`
+func errorStatus(kind domain.ErrorKind) int {
+	switch kind {
+	case domain.KindValidation:
+		return http.StatusBadRequest
+	case domain.KindUnauthorized:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case domain.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
-func NewUnauthorizedErr(message string) *UnauthorizedErr {
-	return &UnauthorizedErr{
-		Message: message,
-		Code:    http.StatusUnauthorized,
+// bindJSON decodes the request body, reporting a body that can't be decoded as a validation error.
+func bindJSON(ctx *gin.Context, v interface{}) error {
+	if err := ctx.ShouldBindJSON(v); err != nil {
+		return domain.NewValidationError("invalid_body", "cannot parse body", nil)
 	}
+
+	return nil
 }
 
-func HandleNotFoundError(ctx *gin.Context, err error) {
-	ctx.JSON(http.StatusOK, map[string]string{
-		"error": err.Error(),
-	})
+// bindQuery decodes the query parameters, reporting the ones that can't be decoded as a validation error.
+func bindQuery(ctx *gin.Context, v interface{}) error {
+	if err := ctx.ShouldBindQuery(v); err != nil {
+		return domain.NewValidationError("invalid_query", "cannot parse query", nil)
+	}
+
+	return nil
 }
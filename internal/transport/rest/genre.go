@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type GenresService interface {
+	List(ctx context.Context) ([]domain.Genre, error)
+	Get(ctx context.Context, slug string) (domain.Genre, error)
+	Create(ctx context.Context, inp domain.GenreInput) (domain.Genre, error)
+	Update(ctx context.Context, slug string, inp domain.GenreInput) (domain.Genre, error)
+	Delete(ctx context.Context, slug string) error
+	Merge(ctx context.Context, slug string, inp domain.MergeGenreInput) (domain.Genre, error)
+}
+
+// Genres serves the genre taxonomy.
+type Genres struct {
+	genresService GenresService
+}
+
+func NewGenres(genresService GenresService) *Genres {
+	return &Genres{genresService: genresService}
+}
+
+// GenreList is the whole genre taxonomy, ordered by name.
+type GenreList struct {
+	Items []domain.Genre `json:"items"`
+}
+
+func (g Genres) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	editors := RequireRole(domain.RoleEditor, domain.RoleAdmin)
+	readScope := RequireScope(domain.ScopeMoviesRead)
+	writeScope := RequireScope(domain.ScopeMoviesWrite)
+
+	genres := r.Group("/genres").Use(middlewares...)
+	{
+		genres.GET("/", readScope, g.getAllGenres)
+		genres.GET("/:slug", readScope, g.getGenre)
+		genres.POST("/", editors, writeScope, g.createGenre)
+		genres.PUT("/:slug", editors, writeScope, g.updateGenre)
+		genres.DELETE("/:slug", editors, writeScope, g.deleteGenre)
+		genres.POST("/:slug/merge", editors, writeScope, g.mergeGenre)
+	}
+}
+
+// @Summary Get All Genres
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description get the genre taxonomy ordered by name
+// @ID get-all-genres
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} GenreList
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres [get]
+func (g Genres) getAllGenres(ctx *gin.Context) {
+	genres, err := g.genresService.List(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, GenreList{Items: genres})
+}
+
+// @Summary Get Genre By Slug
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description get genre by slug
+// @ID get-genre
+// @Accept  json
+// @Produce  json
+// @Param slug path string true "Genre slug"
+// @Success 200 {object} domain.Genre
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres/{slug} [get]
+func (g Genres) getGenre(ctx *gin.Context) {
+	genre, err := g.genresService.Get(ctx, ctx.Param("slug"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, genre)
+}
+
+// @Summary Create Genre
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description create genre, requires editor or admin role
+// @ID create-genre
+// @Accept  json
+// @Produce  json
+// @Param input body domain.GenreInput true "genre"
+// @Success 201 {object} domain.Genre
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the slug or name is taken"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres/ [post]
+func (g Genres) createGenre(ctx *gin.Context) {
+	var inp domain.GenreInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	genre, err := g.genresService.Create(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, genre)
+}
+
+// @Summary Update Genre By Slug
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description rename genre, the slug can be changed too, requires editor or admin role
+// @ID update-genre
+// @Accept  json
+// @Produce  json
+// @Param slug path string true "Genre slug"
+// @Param input body domain.GenreInput true "genre"
+// @Success 200 {object} domain.Genre
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the slug or name is taken"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres/{slug} [put]
+func (g Genres) updateGenre(ctx *gin.Context) {
+	var inp domain.GenreInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	genre, err := g.genresService.Update(ctx, ctx.Param("slug"), inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, genre)
+}
+
+// @Summary Delete Genre By Slug
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description delete genre no movie uses, requires editor or admin role
+// @ID delete-genre
+// @Param slug path string true "Genre slug"
+// @Success 204
+// @Failure 404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "movies use the genre"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres/{slug} [delete]
+func (g Genres) deleteGenre(ctx *gin.Context) {
+	if err := g.genresService.Delete(ctx, ctx.Param("slug")); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Merge Genre
+// @Security ApiKeyAuth
+// @Tags genres
+// @Description moves the movies of the genre to another genre and deletes the genre, requires editor or admin role
+// @ID merge-genre
+// @Accept  json
+// @Produce  json
+// @Param slug path string true "slug of the merged genre"
+// @Param input body domain.MergeGenreInput true "genre that takes over the movies"
+// @Success 200 {object} domain.Genre
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /genres/{slug}/merge [post]
+func (g Genres) mergeGenre(ctx *gin.Context) {
+	var inp domain.MergeGenreInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	genre, err := g.genresService.Merge(ctx, ctx.Param("slug"), inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, genre)
+}
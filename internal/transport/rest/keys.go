@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/pkg/jwks"
+)
+
+type KeySet interface {
+	JWKS() jwks.Set
+}
+
+// Keys publishes the public keys access tokens are signed with, so that other
+// services can verify the tokens on their own.
+type Keys struct {
+	keySet KeySet
+}
+
+func NewKeys(keySet KeySet) *Keys {
+	return &Keys{keySet: keySet}
+}
+
+func (k *Keys) InjectRoutes(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", k.getJWKS)
+}
+
+// @Summary JWKS
+// @Tags auth
+// @Description returns the public keys access tokens are verified with
+// @ID jwks
+// @Produce  json
+// @Success 200 {object} jwks.Set
+// @Router /.well-known/jwks.json [get]
+func (k *Keys) getJWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, k.keySet.JWKS())
+}
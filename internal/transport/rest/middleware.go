@@ -1,13 +1,12 @@
 package rest
 
 import (
-	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,9 +14,15 @@ type CtxValue int
 
 const (
 	ctxUserID CtxValue = iota
+	ctxPrincipal
 )
 
-const AuthorizationHeaderName = "Authorization"
+const (
+	AuthorizationHeaderName = "Authorization"
+
+	bearerScheme = "Bearer"
+	apiKeyScheme = "ApiKey"
+)
 
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -37,42 +42,120 @@ func LoggingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AuthMiddleware authenticates the request by a JWT access token ("Bearer <token>")
+// or by an API key ("ApiKey <key>").
 func (a *Auth) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := getTokenFromRequest(c)
+		scheme, token, err := getTokenFromRequest(c)
 		if err != nil {
-			logError("authMiddleware", err)
-			c.JSON(http.StatusUnauthorized, nil)
+			c.Error(err)
+			c.Abort()
 			return
 		}
 
-		uid, err := a.userService.ParseToken(c, token)
+		var principal domain.Principal
+		if scheme == apiKeyScheme {
+			principal, err = a.apiKeyService.ParseAPIKey(c, token)
+		} else {
+			principal, err = a.userService.ParseToken(c, token)
+		}
 		if err != nil {
-			logError("authMiddleware", err)
-			c.JSON(http.StatusUnauthorized, NewUnauthorizedErr("bad Authorization token"))
+			c.Error(err)
+			c.Abort()
 			return
 		}
 
-		c.Set(fmt.Sprintf("%d", ctxUserID), uid)
+		c.Set(fmt.Sprintf("%d", ctxUserID), principal.UserID)
+		c.Set(fmt.Sprintf("%d", ctxPrincipal), principal)
 
 		c.Next()
 	}
 }
 
-func getTokenFromRequest(c *gin.Context) (string, error) {
+// RequireRole lets the request through only if the authenticated user has one of the roles,
+// and two-factor authentication enabled if the role requires it. It must be used after AuthMiddleware.
+func RequireRole(roles ...domain.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := getPrincipal(c)
+
+		for _, r := range roles {
+			if principal.Role != r {
+				continue
+			}
+
+			if r.RequiresTwoFactor() && !principal.TwoFactor {
+				c.Error(domain.ErrTwoFactorRequired)
+				c.Abort()
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		c.Error(domain.ErrInsufficientRole)
+		c.Abort()
+	}
+}
+
+// RequireScope lets API keys through only if they have the scope. Access tokens
+// are not limited by scopes. It must be used after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := getPrincipal(c)
+
+		if !principal.HasScope(scope) {
+			c.Error(domain.ErrInsufficientScope)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects API keys, leaving the route to users signed in with an access token.
+// It guards the account management routes. It must be used after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := getPrincipal(c)
+
+		if principal.APIKeyID != 0 {
+			c.Error(domain.ErrSessionRequired)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// getPrincipal returns the caller authenticated by AuthMiddleware.
+func getPrincipal(c *gin.Context) (domain.Principal, bool) {
+	v, ok := c.Get(fmt.Sprintf("%d", ctxPrincipal))
+	if !ok {
+		return domain.Principal{}, false
+	}
+
+	principal, ok := v.(domain.Principal)
+
+	return principal, ok
+}
+
+func getTokenFromRequest(c *gin.Context) (string, string, error) {
 	header := c.GetHeader(AuthorizationHeaderName)
 	if header == "" {
-		return "", errors.New("empty auth header")
+		return "", "", errEmptyAuthHeader
 	}
 
 	headerParts := strings.Split(header, " ")
-	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return "", errors.New("invalid auth header")
+	if len(headerParts) != 2 || headerParts[0] != bearerScheme && headerParts[0] != apiKeyScheme {
+		return "", "", errInvalidAuthHeader
 	}
 
 	if len(headerParts[1]) == 0 {
-		return "", errors.New("token is empty")
+		return "", "", errEmptyToken
 	}
 
-	return headerParts[1], nil
+	return headerParts[0], headerParts[1], nil
 }
@@ -2,8 +2,9 @@ package rest
 
 import (
 	"context"
-	"database/sql"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -11,13 +12,27 @@ import (
 )
 
 type Movies interface {
-	List(ctx context.Context) (domain.ListMovie, error)
+	List(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, error)
+	ListByCursor(ctx context.Context, inp domain.ListMoviesInput) (domain.ListMovie, int, string, error)
 	Get(ctx context.Context, id int) (domain.Movie, error)
-	Create(ctx context.Context, movie domain.Movie) (domain.Movie, error)
-	Update(ctx context.Context, id int, movie domain.Movie) (domain.Movie, error)
+	Search(ctx context.Context, inp domain.MovieSearchInput) ([]domain.MovieSearchResult, int, error)
+	FindDuplicates(ctx context.Context, inp domain.MovieDuplicateCheckInput) ([]domain.MovieDuplicate, error)
+	Create(ctx context.Context, inp domain.MovieInput) (domain.Movie, error)
+	Update(ctx context.Context, id int, inp domain.MovieInput) (domain.Movie, error)
+	Patch(ctx context.Context, id int, patch domain.MoviePatch) (domain.Movie, error)
+	SetCast(ctx context.Context, id int, inp domain.SetCastInput) (domain.Movie, error)
+	SetCrew(ctx context.Context, id int, inp domain.SetCrewInput) (domain.Movie, error)
+	Rate(ctx context.Context, id int, userID int64, inp domain.RatingInput) (domain.Movie, error)
+	Unrate(ctx context.Context, id int, userID int64) error
+	UserRatings(ctx context.Context, userID int64, movies ...*domain.Movie) error
 	Delete(ctx context.Context, id int) error
 }
 
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
 func NewMovie(movieService Movies) *Movie {
 	return &Movie{movieService: movieService}
 }
@@ -26,37 +41,219 @@ type Movie struct {
 	movieService Movies
 }
 
+// MoviesPage is a single page of the movie listing.
+type MoviesPage struct {
+	Items      domain.ListMovie `json:"items"`
+	Total      int              `json:"total"`
+	Page       int              `json:"page,omitempty"`
+	Limit      int              `json:"limit"`
+	Next       string           `json:"next,omitempty"`
+	Prev       string           `json:"prev,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// MovieSearchPage is a single page of movie search results, ordered by relevance.
+type MovieSearchPage struct {
+	Items []domain.MovieSearchResult `json:"items"`
+	Total int                        `json:"total"`
+	Page  int                        `json:"page"`
+	Limit int                        `json:"limit"`
+	Next  string                     `json:"next,omitempty"`
+	Prev  string                     `json:"prev,omitempty"`
+}
+
+// MovieDuplicates lists the existing movies that look like the checked one, the most similar first.
+type MovieDuplicates struct {
+	Items []domain.MovieDuplicate `json:"items"`
+}
+
 func (m Movie) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	editors := RequireRole(domain.RoleEditor, domain.RoleAdmin)
+	readScope := RequireScope(domain.ScopeMoviesRead)
+	writeScope := RequireScope(domain.ScopeMoviesWrite)
+
 	movies := r.Group("/movies").Use(middlewares...)
 	{
-		movies.GET("/", m.getAllMovies)
-		movies.GET("/:id", m.getMovie)
-		movies.POST("/", m.createMovie)
-		movies.PUT("/:id", m.updateMovie)
-		movies.DELETE("/:id", m.deleteMovie)
+		movies.GET("/", readScope, m.getAllMovies)
+		movies.GET("/search", readScope, m.searchMovies)
+		movies.GET("/:id", readScope, m.getMovie)
+		movies.POST("/duplicates/check", editors, readScope, m.checkDuplicates)
+		movies.POST("/", editors, writeScope, m.createMovie)
+		movies.PUT("/:id", editors, writeScope, m.updateMovie)
+		movies.PATCH("/:id", editors, writeScope, m.patchMovie)
+		movies.PUT("/:id/cast", editors, writeScope, m.setCast)
+		movies.PUT("/:id/crew", editors, writeScope, m.setCrew)
+		movies.PUT("/:id/rating", writeScope, m.rateMovie)
+		movies.DELETE("/:id/rating", writeScope, m.unrateMovie)
+		movies.DELETE("/:id", editors, writeScope, m.deleteMovie)
 	}
 }
 
 // @Summary Get All Movies
 // @Security ApiKeyAuth
 // @Tags movies
-// @Description get all movies
+// @Description get paginated, filtered and sorted list of movies
 // @ID get-all-movies
 // @Accept  json
 // @Produce  json
-// @Success 200 {object} domain.ListMovie
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} InternalServerErr
-// @Failure default {object} InternalServerErr
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Param sort_by query string false "sort field, rating sorts by the Bayesian-weighted score" Enums(id, name, production_year, rating)
+// @Param order query string false "sort direction" Enums(asc, desc)
+// @Param genre query []string false "genre slugs, repeated or comma-separated, the movies have all of them" collectionFormat(multi)
+// @Param year_from query int false "minimal production year"
+// @Param year_to query int false "maximal production year"
+// @Param name query string false "movie name prefix"
+// @Param director query int false "person id of the Director"
+// @Param writer query int false "person id of the Writer"
+// @Param composer query int false "person id of the Original Music Composer"
+// @Param cinematographer query int false "person id of the Director of Photography"
+// @Param paging query string false "paging mode, cursor mode keeps pages stable while the catalogue changes" Enums(offset, cursor)
+// @Param cursor query string false "next_cursor of the previous page, implies cursor paging"
+// @Success 200 {object} MoviesPage
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /movies [get]
 func (m Movie) getAllMovies(ctx *gin.Context) {
-	movies, err := m.movieService.List(ctx)
+	var inp domain.ListMoviesInput
+	if err := bindQuery(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	inp = inp.WithDefaults()
+
+	if inp.UsesCursor() {
+		m.getMoviesByCursor(ctx, inp)
+		return
+	}
+
+	movies, total, err := m.movieService.List(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, movies.Refs()...); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page := MoviesPage{
+		Items: movies,
+		Total: total,
+		Page:  inp.Page,
+		Limit: inp.Limit,
+	}
+
+	if inp.Offset()+len(movies) < total {
+		page.Next = pageLink(ctx.Request.URL, inp.Page+1)
+	}
+
+	if inp.Page > 1 {
+		page.Prev = pageLink(ctx.Request.URL, inp.Page-1)
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (m Movie) getMoviesByCursor(ctx *gin.Context, inp domain.ListMoviesInput) {
+	movies, total, next, err := m.movieService.ListByCursor(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, movies.Refs()...); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MoviesPage{
+		Items:      movies,
+		Total:      total,
+		Limit:      inp.Limit,
+		NextCursor: next,
+	})
+}
+
+// pageLink returns the request URL pointing to another page of the same listing.
+func pageLink(u *url.URL, page int) string {
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+
+	link := url.URL{Path: u.Path, RawQuery: query.Encode()}
+
+	return link.String()
+}
+
+// @Summary Search Movies
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description full-text search over movie name, cast names and description
+// @ID search-movies
+// @Accept  json
+// @Produce  json
+// @Param q query string true "search query, supports quoted phrases, or and -word"
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Success 200 {object} MovieSearchPage
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/search [get]
+func (m Movie) searchMovies(ctx *gin.Context) {
+	var inp domain.MovieSearchInput
+	if err := bindQuery(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	inp = inp.WithDefaults()
+
+	results, total, err := m.movieService.Search(ctx, inp)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("transport | movieService.List error"))
+		ctx.Error(err)
+		return
+	}
+
+	movies := make([]*domain.Movie, 0, len(results))
+	for i := range results {
+		movies = append(movies, &results[i].Movie)
+	}
+
+	if err := m.userRatings(ctx, movies...); err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, movies)
+	page := MovieSearchPage{
+		Items: results,
+		Total: total,
+		Page:  inp.Page,
+		Limit: inp.Limit,
+	}
+
+	if inp.Offset()+len(results) < total {
+		page.Next = pageLink(ctx.Request.URL, inp.Page+1)
+	}
+
+	if inp.Page > 1 {
+		page.Prev = pageLink(ctx.Request.URL, inp.Page-1)
+	}
+
+	ctx.JSON(http.StatusOK, page)
 }
 
 // @Summary Get  Movie By ID
@@ -68,56 +265,106 @@ func (m Movie) getAllMovies(ctx *gin.Context) {
 // @Produce  json
 // @Param id path int true "Movie ID"
 // @Success 200 {object} domain.Movie
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} InternalServerErr
-// @Failure default {object} InternalServerErr
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /movies/{id} [get]
 func (m Movie) getMovie(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		fields := map[string]string{"id": "should be an integer"}
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("validation error", fields))
+		ctx.Error(errInvalidID)
 		return
 	}
 
 	movie, err := m.movieService.Get(ctx, id)
 	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			ctx.JSON(http.StatusNotFound, NewNotFoundErr("movie not found"))
-		default:
-			ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("transport | movieService.Get error"))
-		}
+		ctx.Error(err)
+		return
+	}
 
+	if err := m.userRatings(ctx, &movie); err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, movie)
 }
 
+// @Summary Check Movie Duplicates
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description finds the movies of the same year with similar names, ignoring case and diacritics, requires editor or admin role
+// @ID check-movie-duplicates
+// @Accept  json
+// @Produce  json
+// @Param input body domain.MovieDuplicateCheckInput true "name and production year of the new movie"
+// @Success 200 {object} MovieDuplicates
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/duplicates/check [post]
+func (m Movie) checkDuplicates(ctx *gin.Context) {
+	var inp domain.MovieDuplicateCheckInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	duplicates, err := m.movieService.FindDuplicates(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	movies := make([]*domain.Movie, 0, len(duplicates))
+	for i := range duplicates {
+		movies = append(movies, &duplicates[i].Movie)
+	}
+
+	if err := m.userRatings(ctx, movies...); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MovieDuplicates{Items: duplicates})
+}
+
 // @Summary Create Movie
 // @Security ApiKeyAuth
 // @Tags movies
-// @Description create movie
+// @Description create movie, requires editor or admin role
 // @ID create-movie
 // @Accept  json
 // @Produce  json
-// @Param input body domain.Movie true "movie description"
+// @Param input body domain.MovieInput true "movie description"
 // @Success 200 {object} domain.Movie
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} InternalServerErr
-// @Failure default {object} InternalServerErr
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the name is taken, existing links the movie"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /movies/ [post]
 func (m Movie) createMovie(ctx *gin.Context) {
-	var movie domain.Movie
-	if err := ctx.BindJSON(&movie); err != nil {
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("cannot parse body", nil))
+	var inp domain.MovieInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	createdMovie, err := m.movieService.Create(ctx, movie)
+	createdMovie, err := m.movieService.Create(ctx, inp)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("transport | movieService.Create error"))
+		ctx.Error(err)
 		return
 	}
 
@@ -127,63 +374,307 @@ func (m Movie) createMovie(ctx *gin.Context) {
 // @Summary Update Movie By ID
 // @Security ApiKeyAuth
 // @Tags movies
-// @Description update movie by id
+// @Description update movie by id, requires editor or admin role
 // @ID update-movie
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Movie ID"
-// @Param input body domain.Movie true "movie description"
+// @Param input body domain.MovieInput true "movie description"
 // @Success 200 {object} domain.Movie
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} InternalServerErr
-// @Failure default {object} InternalServerErr
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the name is taken, existing links the movie"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /movies/{id} [put]
 func (m Movie) updateMovie(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		fields := map[string]string{"id": "should be an integer"}
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("validation error", fields))
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.MovieInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	var movie domain.Movie
-	if err := ctx.BindJSON(&movie); err != nil {
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("cannot parse body", nil))
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	updatedMovie, err := m.movieService.Update(ctx, id, movie)
+	updatedMovie, err := m.movieService.Update(ctx, id, inp)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("transport | movieService.Update error"))
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, &updatedMovie); err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, updatedMovie)
 }
 
+// @Summary Patch Movie By ID
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description changes only the given fields of the movie, requires editor or admin role. The body is a JSON Merge Patch (RFC 7396) with the fields to change or a JSON Patch (RFC 6902) with the operations to apply, told apart by Content-Type
+// @ID patch-movie
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param input body domain.MovieInput true "fields to change"
+// @Success 200 {object} domain.Movie
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the name is taken or a test operation failed"
+// @Failure 415 {object} Problem
+// @Header 415 {string} Accept-Patch "supported patch formats"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id} [patch]
+func (m Movie) patchMovie(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var format domain.PatchFormat
+	switch ctx.ContentType() {
+	case mergePatchContentType:
+		format = domain.MergePatch
+	case jsonPatchContentType:
+		format = domain.JSONPatch
+	default:
+		ctx.Header("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		ctx.Error(errUnsupportedPatch)
+		return
+	}
+
+	document, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Error(domain.NewValidationError("invalid_body", "cannot read body", nil))
+		return
+	}
+
+	movie, err := m.movieService.Patch(ctx, id, domain.MoviePatch{Format: format, Document: document})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, &movie); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, movie)
+}
+
+// @Summary Set Movie Cast
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description replaces the cast of the movie, the people are listed in billing order, requires editor or admin role
+// @ID set-movie-cast
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param input body domain.SetCastInput true "the whole cast"
+// @Success 200 {object} domain.Movie
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the person is listed twice with the same character"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/cast [put]
+func (m Movie) setCast(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.SetCastInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	movie, err := m.movieService.SetCast(ctx, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, &movie); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, movie)
+}
+
+// @Summary Set Movie Crew
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description replaces the crew of the movie with people credited by department and job, requires editor or admin role
+// @ID set-movie-crew
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param input body domain.SetCrewInput true "the whole crew"
+// @Success 200 {object} domain.Movie
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the person is listed twice with the same job"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/crew [put]
+func (m Movie) setCrew(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.SetCrewInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	movie, err := m.movieService.SetCrew(ctx, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := m.userRatings(ctx, &movie); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, movie)
+}
+
+// @Summary Rate Movie
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description saves the vote of the caller from 1 to 10, replacing the previous one
+// @ID rate-movie
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param input body domain.RatingInput true "vote"
+// @Success 200 {object} domain.Movie
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/rating [put]
+func (m Movie) rateMovie(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.RatingInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	movie, err := m.movieService.Rate(ctx, id, principal.UserID, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, movie)
+}
+
+// @Summary Delete Movie Rating
+// @Security ApiKeyAuth
+// @Tags movies
+// @Description withdraws the vote of the caller
+// @ID unrate-movie
+// @Param id path int true "Movie ID"
+// @Success 204
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/rating [delete]
+func (m Movie) unrateMovie(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	if err := m.movieService.Unrate(ctx, id, principal.UserID); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// userRatings fills in the votes of the caller for the movies.
+func (m Movie) userRatings(ctx *gin.Context, movies ...*domain.Movie) error {
+	principal, _ := getPrincipal(ctx)
+
+	return m.movieService.UserRatings(ctx, principal.UserID, movies...)
+}
+
 // @Summary Delete  Movie By ID
 // @Security ApiKeyAuth
 // @Tags movies
-// @Description delete movie by id
+// @Description delete movie by id, requires editor or admin role
 // @ID delete-movie
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Movie ID"
 // @Success 200 {object} domain.Movie
-// @Failure 400,404 {object} BadRequestErr
-// @Failure 500 {object} InternalServerErr
-// @Failure default {object} InternalServerErr
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
 // @Router /movies/{id} [delete]
 func (m Movie) deleteMovie(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		fields := map[string]string{"id": "should be an integer"}
-		ctx.JSON(http.StatusBadRequest, NewBadRequestErr("validation error", fields))
+		ctx.Error(errInvalidID)
 		return
 	}
 
 	if err := m.movieService.Delete(ctx, id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, NewInternalServerErr("transport | movieService.Delete error"))
+		ctx.Error(err)
 		return
 	}
 
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+const oidcStateCookie = "oidc-state"
+
+type OIDCService interface {
+	Login(ctx context.Context) (string, string, error)
+	StartLink(ctx context.Context, userID int64, inp domain.LinkIdentityInput) (string, string, error)
+	Callback(ctx context.Context, code, state, signedState string, client domain.ClientInfo) (domain.SignInResult, error)
+}
+
+// OIDC signs users in with the company identity provider. The cookies are set for
+// cookieDomain, the host of the application, and are secure when it's served over https.
+type OIDC struct {
+	oidcService  OIDCService
+	cookieDomain string
+	secureCookie bool
+}
+
+func NewOIDC(oidcService OIDCService, cookieDomain string, secureCookie bool) *OIDC {
+	return &OIDC{oidcService: oidcService, cookieDomain: cookieDomain, secureCookie: secureCookie}
+}
+
+func (o *OIDC) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	oidc := r.Group("/auth/oidc").Use(middlewares...)
+	{
+		oidc.GET("/login", o.login)
+		oidc.GET("/callback", o.callback)
+	}
+}
+
+// InjectLinkRoutes adds the routes of the signed-in user, the middlewares have to authenticate them.
+func (o *OIDC) InjectLinkRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	me := r.Group("/me/oidc").Use(middlewares...)
+	{
+		me.POST("/link", o.link)
+	}
+}
+
+// @Summary OIDC Login
+// @Tags auth
+// @Description redirects to the identity provider's login page
+// @ID oidc-login
+// @Success 302
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/oidc/login [get]
+func (o *OIDC) login(ctx *gin.Context) {
+	authURL, state, err := o.oidcService.Login(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetCookie(oidcStateCookie, state, 600, "/auth/oidc", o.cookieDomain, o.secureCookie, true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Link OIDC Account
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description starts the identity provider login that links the provider's account to the user, returns the url of the provider's login page to open. The code is required when two-factor authentication is enabled
+// @ID oidc-link
+// @Accept  json
+// @Produce  json
+// @Param input body domain.LinkIdentityInput true "password and code"
+// @Success 200 {string} string "url"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/oidc/link [post]
+func (o *OIDC) link(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.LinkIdentityInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	authURL, state, err := o.oidcService.StartLink(ctx, principal.UserID, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetCookie(oidcStateCookie, state, 600, "/auth/oidc", o.cookieDomain, o.secureCookie, true)
+
+	ctx.JSON(http.StatusOK, map[string]string{
+		"url": authURL,
+	})
+}
+
+// @Summary OIDC Callback
+// @Tags auth
+// @Description completes the identity provider login, returns accessToken and sets in cookies refresh-token, or an mfa_token when two-factor authentication is enabled
+// @ID oidc-callback
+// @Produce  json
+// @Param code query string true "authorization code"
+// @Param state query string true "login state"
+// @Success 200 {string} string "token"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /auth/oidc/callback [get]
+func (o *OIDC) callback(ctx *gin.Context) {
+	signedState, _ := ctx.Cookie(oidcStateCookie)
+	ctx.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", o.cookieDomain, o.secureCookie, true)
+
+	// the provider's text is not repeated to the client, it comes from the query string anyone can craft
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		logError("oidcCallback", fmt.Errorf("%w: %s: %s", domain.ErrOIDCLoginFailed, providerErr, ctx.Query("error_description")))
+		ctx.Error(domain.ErrOIDCLoginFailed)
+		return
+	}
+
+	code, state := ctx.Query("code"), ctx.Query("state")
+	if code == "" || state == "" || signedState == "" {
+		ctx.Error(domain.ErrInvalidOIDCState)
+		return
+	}
+
+	res, err := o.oidcService.Callback(ctx, code, state, signedState, clientInfo(ctx))
+	if err != nil {
+		// the client only sees that the login failed, the reason is for the logs
+		if errors.Is(err, domain.ErrOIDCLoginFailed) {
+			logError("oidcCallback", err)
+		}
+
+		ctx.Error(err)
+		return
+	}
+
+	if res.MFAToken != "" {
+		ctx.JSON(http.StatusOK, map[string]string{
+			"mfa_token": res.MFAToken,
+		})
+		return
+	}
+
+	ctx.SetCookie("refresh-token", res.RefreshToken, 3600, "/auth", o.cookieDomain, o.secureCookie, true)
+
+	ctx.JSON(http.StatusOK, map[string]string{
+		"token": res.AccessToken,
+	})
+}
@@ -0,0 +1,274 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type PeopleService interface {
+	List(ctx context.Context, inp domain.ListPeopleInput) ([]domain.Person, int, error)
+	Get(ctx context.Context, id int64) (domain.Person, error)
+	Create(ctx context.Context, inp domain.PersonInput) (domain.Person, error)
+	Update(ctx context.Context, id int64, inp domain.PersonInput) (domain.Person, error)
+	Delete(ctx context.Context, id int64) error
+	Movies(ctx context.Context, id int64) ([]domain.PersonMovie, error)
+}
+
+// People serves the actors and other people credited in movies.
+type People struct {
+	peopleService PeopleService
+}
+
+func NewPeople(peopleService PeopleService) *People {
+	return &People{peopleService: peopleService}
+}
+
+// PeoplePage is a single page of the people listing, ordered by name.
+type PeoplePage struct {
+	Items []domain.Person `json:"items"`
+	Total int             `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+	Next  string          `json:"next,omitempty"`
+	Prev  string          `json:"prev,omitempty"`
+}
+
+// PersonMovies is the filmography of a person, the latest movies first.
+type PersonMovies struct {
+	Items []domain.PersonMovie `json:"items"`
+}
+
+func (p People) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	editors := RequireRole(domain.RoleEditor, domain.RoleAdmin)
+	readScope := RequireScope(domain.ScopeMoviesRead)
+	writeScope := RequireScope(domain.ScopeMoviesWrite)
+
+	people := r.Group("/people").Use(middlewares...)
+	{
+		people.GET("/", readScope, p.getAllPeople)
+		people.GET("/:id", readScope, p.getPerson)
+		people.GET("/:id/movies", readScope, p.getPersonMovies)
+		people.POST("/", editors, writeScope, p.createPerson)
+		people.PUT("/:id", editors, writeScope, p.updatePerson)
+		people.DELETE("/:id", editors, writeScope, p.deletePerson)
+	}
+}
+
+// @Summary Get All People
+// @Security ApiKeyAuth
+// @Tags people
+// @Description get paginated list of people ordered by name
+// @ID get-all-people
+// @Accept  json
+// @Produce  json
+// @Param name query string false "part of the name"
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Success 200 {object} PeoplePage
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people [get]
+func (p People) getAllPeople(ctx *gin.Context) {
+	var inp domain.ListPeopleInput
+	if err := bindQuery(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	inp = inp.WithDefaults()
+
+	people, total, err := p.peopleService.List(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page := PeoplePage{
+		Items: people,
+		Total: total,
+		Page:  inp.Page,
+		Limit: inp.Limit,
+	}
+
+	if inp.Offset()+len(people) < total {
+		page.Next = pageLink(ctx.Request.URL, inp.Page+1)
+	}
+
+	if inp.Page > 1 {
+		page.Prev = pageLink(ctx.Request.URL, inp.Page-1)
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary Get Person By ID
+// @Security ApiKeyAuth
+// @Tags people
+// @Description get person by id
+// @ID get-person
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Person ID"
+// @Success 200 {object} domain.Person
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people/{id} [get]
+func (p People) getPerson(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	person, err := p.peopleService.Get(ctx, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, person)
+}
+
+// @Summary Get Person Movies
+// @Security ApiKeyAuth
+// @Tags people
+// @Description get the filmography of the person: roles in casts and jobs in crews, the latest movies first
+// @ID get-person-movies
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Person ID"
+// @Success 200 {object} PersonMovies
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people/{id}/movies [get]
+func (p People) getPersonMovies(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	movies, err := p.peopleService.Movies(ctx, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, PersonMovies{Items: movies})
+}
+
+// @Summary Create Person
+// @Security ApiKeyAuth
+// @Tags people
+// @Description create person, requires editor or admin role
+// @ID create-person
+// @Accept  json
+// @Produce  json
+// @Param input body domain.PersonInput true "person"
+// @Success 201 {object} domain.Person
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people/ [post]
+func (p People) createPerson(ctx *gin.Context) {
+	var inp domain.PersonInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	person, err := p.peopleService.Create(ctx, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, person)
+}
+
+// @Summary Update Person By ID
+// @Security ApiKeyAuth
+// @Tags people
+// @Description update person by id, requires editor or admin role
+// @ID update-person
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Person ID"
+// @Param input body domain.PersonInput true "person"
+// @Success 200 {object} domain.Person
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people/{id} [put]
+func (p People) updatePerson(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.PersonInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	person, err := p.peopleService.Update(ctx, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, person)
+}
+
+// @Summary Delete Person By ID
+// @Security ApiKeyAuth
+// @Tags people
+// @Description delete person by id together with their roles in movies, requires editor or admin role
+// @ID delete-person
+// @Param id path int true "Person ID"
+// @Success 204
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /people/{id} [delete]
+func (p People) deletePerson(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	if err := p.peopleService.Delete(ctx, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
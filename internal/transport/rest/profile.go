@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type ProfileService interface {
+	Profile(ctx context.Context, userID int64) (domain.User, error)
+	UpdateProfile(ctx context.Context, userID int64, inp domain.UpdateProfileInput) (domain.User, error)
+	ChangePassword(ctx context.Context, principal domain.Principal, inp domain.ChangePasswordInput) error
+	DeleteAccount(ctx context.Context, principal domain.Principal) error
+}
+
+// Profile serves the account of the authenticated user.
+type Profile struct {
+	profileService ProfileService
+}
+
+func NewProfile(profileService ProfileService) *Profile {
+	return &Profile{profileService: profileService}
+}
+
+func (p *Profile) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	me := r.Group("/me").Use(middlewares...)
+	{
+		me.GET("", p.getProfile)
+		me.PATCH("", p.updateProfile)
+		me.POST("/password", p.changePassword)
+		me.DELETE("", p.deleteAccount)
+	}
+}
+
+// @Summary Get Profile
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description returns the current user
+// @ID get-profile
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} domain.User
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me [get]
+func (p *Profile) getProfile(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	user, err := p.profileService.Profile(ctx, principal.UserID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// @Summary Update Profile
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description updates name and email of the current user, fields left out are not changed
+// @ID update-profile
+// @Accept  json
+// @Produce  json
+// @Param input body domain.UpdateProfileInput true "profile fields"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me [patch]
+func (p *Profile) updateProfile(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.UpdateProfileInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	user, err := p.profileService.UpdateProfile(ctx, principal.UserID, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// @Summary Change Password
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description changes the password of the current user and signs out every other session
+// @ID change-password
+// @Accept  json
+// @Produce  json
+// @Param input body domain.ChangePasswordInput true "current and new password"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/password [post]
+func (p *Profile) changePassword(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.ChangePasswordInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := p.profileService.ChangePassword(ctx, principal, inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Delete Account
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description deletes the current user together with all the sessions
+// @ID delete-account
+// @Accept  json
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me [delete]
+func (p *Profile) deleteAccount(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	if err := p.profileService.DeleteAccount(ctx, principal); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetCookie("refresh-token", "", -1, "/auth", "localhost", false, true)
+
+	ctx.Status(http.StatusNoContent)
+}
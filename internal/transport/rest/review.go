@@ -0,0 +1,455 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type ReviewsService interface {
+	ListByMovie(ctx context.Context, caller domain.Principal, movieID int64, inp domain.ListReviewsInput) ([]domain.Review, int, error)
+	ListByUser(ctx context.Context, caller domain.Principal, userID int64, inp domain.ListReviewsInput) ([]domain.Review, int, error)
+	ListForModeration(ctx context.Context, inp domain.ListReviewsInput) ([]domain.Review, int, error)
+	Get(ctx context.Context, caller domain.Principal, id int64) (domain.Review, error)
+	Create(ctx context.Context, caller domain.Principal, movieID int64, inp domain.ReviewInput) (domain.Review, error)
+	Update(ctx context.Context, caller domain.Principal, id int64, inp domain.ReviewInput) (domain.Review, error)
+	Delete(ctx context.Context, caller domain.Principal, id int64) error
+	Moderate(ctx context.Context, caller domain.Principal, id int64, inp domain.ModerateReviewInput) (domain.Review, error)
+	Vote(ctx context.Context, caller domain.Principal, id int64, inp domain.ReviewVoteInput) (domain.Review, error)
+	DeleteVote(ctx context.Context, caller domain.Principal, id int64) error
+}
+
+// Reviews serves the reviews users write about movies and their moderation.
+type Reviews struct {
+	reviewsService ReviewsService
+}
+
+func NewReviews(reviewsService ReviewsService) *Reviews {
+	return &Reviews{reviewsService: reviewsService}
+}
+
+// ReviewsPage is a single page of a review listing.
+type ReviewsPage struct {
+	Items []domain.Review `json:"items"`
+	Total int             `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+	Next  string          `json:"next,omitempty"`
+	Prev  string          `json:"prev,omitempty"`
+}
+
+func (rv Reviews) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	editors := RequireRole(domain.RoleEditor, domain.RoleAdmin)
+	readScope := RequireScope(domain.ScopeMoviesRead)
+	writeScope := RequireScope(domain.ScopeMoviesWrite)
+
+	movieReviews := r.Group("/movies/:id/reviews").Use(middlewares...)
+	{
+		movieReviews.GET("/", readScope, rv.getMovieReviews)
+		movieReviews.POST("/", writeScope, rv.createReview)
+	}
+
+	userReviews := r.Group("/users/:id/reviews").Use(middlewares...)
+	{
+		userReviews.GET("/", readScope, rv.getUserReviews)
+	}
+
+	reviews := r.Group("/reviews").Use(middlewares...)
+	{
+		reviews.GET("/", editors, readScope, rv.getModerationQueue)
+		reviews.GET("/:id", readScope, rv.getReview)
+		reviews.PUT("/:id", writeScope, rv.updateReview)
+		reviews.DELETE("/:id", writeScope, rv.deleteReview)
+		reviews.PUT("/:id/moderation", editors, writeScope, rv.moderateReview)
+		reviews.PUT("/:id/vote", writeScope, rv.voteReview)
+		reviews.DELETE("/:id/vote", writeScope, rv.deleteReviewVote)
+	}
+}
+
+// @Summary Get Movie Reviews
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description get the approved reviews of the movie, the newest first. Editors may filter by another status
+// @ID get-movie-reviews
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param status query string false "moderation status, for editors" Enums(pending, approved, rejected)
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Success 200 {object} ReviewsPage
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/reviews [get]
+func (rv Reviews) getMovieReviews(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	rv.listReviews(ctx, func(caller domain.Principal, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+		return rv.reviewsService.ListByMovie(ctx, caller, id, inp)
+	})
+}
+
+// @Summary Get User Reviews
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description get the reviews of the user, the newest first. The user and editors see the reviews in every status, the others only the approved ones
+// @ID get-user-reviews
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param status query string false "moderation status, for the user and editors" Enums(pending, approved, rejected)
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Success 200 {object} ReviewsPage
+// @Failure 400 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /users/{id}/reviews [get]
+func (rv Reviews) getUserReviews(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	rv.listReviews(ctx, func(caller domain.Principal, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+		return rv.reviewsService.ListByUser(ctx, caller, id, inp)
+	})
+}
+
+// @Summary Get Moderation Queue
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description get the reviews waiting for moderation, or in another status, the oldest first, requires editor or admin role
+// @ID get-moderation-queue
+// @Accept  json
+// @Produce  json
+// @Param status query string false "moderation status, pending by default" Enums(pending, approved, rejected)
+// @Param page query int false "page number, starts from 1"
+// @Param limit query int false "page size, up to 100"
+// @Success 200 {object} ReviewsPage
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews [get]
+func (rv Reviews) getModerationQueue(ctx *gin.Context) {
+	rv.listReviews(ctx, func(_ domain.Principal, inp domain.ListReviewsInput) ([]domain.Review, int, error) {
+		return rv.reviewsService.ListForModeration(ctx, inp)
+	})
+}
+
+// listReviews binds the paging of a review listing and responds with the page the list function returns.
+func (rv Reviews) listReviews(ctx *gin.Context, list func(caller domain.Principal, inp domain.ListReviewsInput) ([]domain.Review, int, error)) {
+	var inp domain.ListReviewsInput
+	if err := bindQuery(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	inp = inp.WithDefaults()
+	principal, _ := getPrincipal(ctx)
+
+	reviews, total, err := list(principal, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page := ReviewsPage{
+		Items: reviews,
+		Total: total,
+		Page:  inp.Page,
+		Limit: inp.Limit,
+	}
+
+	if inp.Offset()+len(reviews) < total {
+		page.Next = pageLink(ctx.Request.URL, inp.Page+1)
+	}
+
+	if inp.Page > 1 {
+		page.Prev = pageLink(ctx.Request.URL, inp.Page-1)
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary Create Review
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description post a review of the movie, it's public once an editor approves it. A user reviews a movie once
+// @ID create-review
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Movie ID"
+// @Param input body domain.ReviewInput true "review"
+// @Success 201 {object} domain.Review
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the user has reviewed the movie already"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /movies/{id}/reviews [post]
+func (rv Reviews) createReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.ReviewInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	review, err := rv.reviewsService.Create(ctx, principal, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, review)
+}
+
+// @Summary Get Review By ID
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description get review by id, reviews that aren't approved are seen only by their authors and editors
+// @ID get-review
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Success 200 {object} domain.Review
+// @Failure 400,404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id} [get]
+func (rv Reviews) getReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	review, err := rv.reviewsService.Get(ctx, principal, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, review)
+}
+
+// @Summary Update Review By ID
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description change the text of the own review, the review goes back to moderation and loses its votes
+// @ID update-review
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Param input body domain.ReviewInput true "review"
+// @Success 200 {object} domain.Review
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id} [put]
+func (rv Reviews) updateReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.ReviewInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	review, err := rv.reviewsService.Update(ctx, principal, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, review)
+}
+
+// @Summary Delete Review By ID
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description delete the own review, editors delete any review
+// @ID delete-review
+// @Param id path int true "Review ID"
+// @Success 204
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id} [delete]
+func (rv Reviews) deleteReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	if err := rv.reviewsService.Delete(ctx, principal, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Moderate Review
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description approve or reject the review of another user as of its updated_at, the note is shown to the author, requires editor or admin role
+// @ID moderate-review
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Param input body domain.ModerateReviewInput true "decision"
+// @Success 200 {object} domain.Review
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 409 {object} Problem "the review has been edited since"
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id}/moderation [put]
+func (rv Reviews) moderateReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.ModerateReviewInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	review, err := rv.reviewsService.Moderate(ctx, principal, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, review)
+}
+
+// @Summary Vote For Review
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description mark the approved review of another user as helpful or unhelpful, replacing the previous vote
+// @ID vote-review
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Param input body domain.ReviewVoteInput true "vote"
+// @Success 200 {object} domain.Review
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id}/vote [put]
+func (rv Reviews) voteReview(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	var inp domain.ReviewVoteInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	review, err := rv.reviewsService.Vote(ctx, principal, id, inp)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, review)
+}
+
+// @Summary Delete Review Vote
+// @Security ApiKeyAuth
+// @Tags reviews
+// @Description withdraw the vote of the caller
+// @ID delete-review-vote
+// @Param id path int true "Review ID"
+// @Success 204
+// @Failure 400,404 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /reviews/{id}/vote [delete]
+func (rv Reviews) deleteReviewVote(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(errInvalidID)
+		return
+	}
+
+	principal, _ := getPrincipal(ctx)
+
+	if err := rv.reviewsService.DeleteVote(ctx, principal, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
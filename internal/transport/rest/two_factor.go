@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukinairina90/crud_movies/internal/domain"
+)
+
+type TwoFactorService interface {
+	EnrollTwoFactor(ctx context.Context, userID int64) (domain.TwoFactorEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, userID int64, code string) (domain.RecoveryCodes, error)
+	DisableTwoFactor(ctx context.Context, userID int64, inp domain.DisableTwoFactorInput) error
+}
+
+// TwoFactor manages the two-factor authentication of the authenticated user.
+type TwoFactor struct {
+	twoFactorService TwoFactorService
+}
+
+func NewTwoFactor(twoFactorService TwoFactorService) *TwoFactor {
+	return &TwoFactor{twoFactorService: twoFactorService}
+}
+
+func (t *TwoFactor) InjectRoutes(r *gin.Engine, middlewares ...gin.HandlerFunc) {
+	twoFactor := r.Group("/me/2fa").Use(middlewares...)
+	{
+		twoFactor.POST("/enroll", t.enroll)
+		twoFactor.POST("/confirm", t.confirm)
+		twoFactor.POST("/disable", t.disable)
+	}
+}
+
+// @Summary Enroll 2FA
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description generates a secret for the authenticator app, two-factor authentication is enabled once confirmed with a code
+// @ID enroll-2fa
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} domain.TwoFactorEnrollment
+// @Failure 401 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/2fa/enroll [post]
+func (t *TwoFactor) enroll(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	enrollment, err := t.twoFactorService.EnrollTwoFactor(ctx, principal.UserID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm 2FA
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description enables two-factor authentication with a code from the authenticator app, returns the recovery codes once
+// @ID confirm-2fa
+// @Accept  json
+// @Produce  json
+// @Param input body domain.TwoFactorCodeInput true "code"
+// @Success 200 {object} domain.RecoveryCodes
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/2fa/confirm [post]
+func (t *TwoFactor) confirm(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.TwoFactorCodeInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	codes, err := t.twoFactorService.ConfirmTwoFactor(ctx, principal.UserID, inp.Code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, codes)
+}
+
+// @Summary Disable 2FA
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description disables two-factor authentication, requires the password and a code from the authenticator app or a recovery code
+// @ID disable-2fa
+// @Accept  json
+// @Produce  json
+// @Param input body domain.DisableTwoFactorInput true "password and code"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure default {object} Problem
+// @Router /me/2fa/disable [post]
+func (t *TwoFactor) disable(ctx *gin.Context) {
+	principal, _ := getPrincipal(ctx)
+
+	var inp domain.DisableTwoFactorInput
+	if err := bindJSON(ctx, &inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := t.twoFactorService.DisableTwoFactor(ctx, principal.UserID, inp); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
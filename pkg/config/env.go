@@ -16,6 +16,65 @@ type Config struct {
 	SSLMode  bool          `env:"DB_SSL_MODE,required"`
 	TokenTTL time.Duration `env:"TOKEN_TTL,required"`
 	CacheTTL time.Duration `env:"CACHE_TTL,required"`
+
+	CursorSecret string `env:"CURSOR_SECRET,required"`
+	SearchMode   string `env:"SEARCH_MODE" envDefault:"fts"`
+
+	PasswordHash string `env:"PASSWORD_HASH" envDefault:"argon2id"`
+	BcryptCost   int    `env:"BCRYPT_COST" envDefault:"12"`
+
+	// JWTSigningKeyFile is a PEM encoded RSA or Ed25519 private key. The previous signing keys
+	// are listed in JWTVerificationKeyFiles as public keys until the tokens they signed expire.
+	JWTSigningKeyFile       string   `env:"JWT_SIGNING_KEY_FILE"`
+	JWTVerificationKeyFiles []string `env:"JWT_VERIFICATION_KEY_FILES" envSeparator:","`
+
+	// Sign-in is locked out after LoginMaxFailures failed attempts for an account or LoginMaxFailuresPerIP
+	// from an address, for LoginLockout doubled on every further failure up to LoginMaxLockout.
+	LoginAttemptsStore    string        `env:"LOGIN_ATTEMPTS_STORE" envDefault:"postgres"`
+	LoginMaxFailures      int           `env:"LOGIN_MAX_FAILURES" envDefault:"5"`
+	LoginMaxFailuresPerIP int           `env:"LOGIN_MAX_FAILURES_PER_IP" envDefault:"20"`
+	LoginFailureWindow    time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"15m"`
+	LoginLockout          time.Duration `env:"LOGIN_LOCKOUT" envDefault:"30s"`
+	LoginMaxLockout       time.Duration `env:"LOGIN_MAX_LOCKOUT" envDefault:"15m"`
+	// LoginCleanupInterval is how often the ended lockouts are logged as unlocked and the expired counters removed.
+	LoginCleanupInterval time.Duration `env:"LOGIN_CLEANUP_INTERVAL" envDefault:"1m"`
+
+	// Password reset requests are limited to PasswordResetMaxPerEmail per email and PasswordResetMaxPerIP
+	// per client address within PasswordResetWindow.
+	PasswordResetMaxPerEmail int           `env:"PASSWORD_RESET_MAX_PER_EMAIL" envDefault:"3"`
+	PasswordResetMaxPerIP    int           `env:"PASSWORD_RESET_MAX_PER_IP" envDefault:"10"`
+	PasswordResetWindow      time.Duration `env:"PASSWORD_RESET_WINDOW" envDefault:"1h"`
+
+	// TrustedProxies are the addresses or CIDRs of the reverse proxies whose X-Forwarded-For header
+	// is believed. Without them the client address is the peer address of the connection.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+
+	// TOTPIssuer is the name authenticator apps show next to the account.
+	TOTPIssuer string `env:"TOTP_ISSUER" envDefault:"CRUD_movies"`
+	// TOTPEncryptionKey encrypts the TOTP secrets at rest. The secrets become unreadable when it changes.
+	TOTPEncryptionKey string `env:"TOTP_ENCRYPTION_KEY,required"`
+
+	// OIDC login is enabled when OIDCDiscoveryURL is set. OIDCRedirectURL defaults to
+	// the callback route under AppURL.
+	OIDCDiscoveryURL  string   `env:"OIDC_DISCOVERY_URL"`
+	OIDCClientID      string   `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret  string   `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL   string   `env:"OIDC_REDIRECT_URL"`
+	OIDCScopes        []string `env:"OIDC_SCOPES" envSeparator:"," envDefault:"openid,email,profile"`
+	OIDCProvider      string   `env:"OIDC_PROVIDER" envDefault:"oidc"`
+	OIDCAutoProvision bool     `env:"OIDC_AUTO_PROVISION" envDefault:"false"`
+	OIDCStateSecret   string   `env:"OIDC_STATE_SECRET"`
+
+	// AppURL is the public address of the application the links in emails point to.
+	AppURL string `env:"APP_URL" envDefault:"http://localhost:8080"`
+
+	MailDriver string `env:"MAIL_DRIVER" envDefault:"file"`
+	MailFrom   string `env:"MAIL_FROM" envDefault:"no-reply@localhost"`
+	MailDir    string `env:"MAIL_DIR" envDefault:"mails"`
+	SMTPHost   string `env:"SMTP_HOST"`
+	SMTPPort   string `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUser   string `env:"SMTP_USER"`
+	SMTPPass   string `env:"SMTP_PASS"`
 }
 
 func Parse() (Config, error) {
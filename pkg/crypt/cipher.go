@@ -0,0 +1,77 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// prefix marks the encrypted values, so they can be told apart from the ones stored before the encryption.
+const prefix = "v1:"
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Cipher encrypts the secrets the application has to read back, like the TOTP secrets,
+// with AES-256-GCM. The key is the SHA-256 hash of the configured secret.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+func NewCipher(secret []byte) (*Cipher, error) {
+	key := sha256.Sum256(secret)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the plaintext encrypted with a random nonce, as a printable string.
+func (c Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value returned by Encrypt. Values encrypted with
+// another key or altered are rejected.
+func (c Cipher) Decrypt(ciphertext string) (string, error) {
+	if !c.Encrypted(ciphertext) {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(ciphertext, prefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+// Encrypted reports whether the value has been returned by Encrypt.
+func (c Cipher) Encrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
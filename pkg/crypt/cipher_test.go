@@ -0,0 +1,95 @@
+package crypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher([]byte("test key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	first, err := c.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := c.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Error("the same plaintext encrypted twice gives the same ciphertext")
+	}
+
+	if strings.Contains(first, secret) || !c.Encrypted(first) || c.Encrypted(secret) {
+		t.Errorf("Encrypt(%q) = %q", secret, first)
+	}
+
+	for _, ciphertext := range []string{first, second} {
+		plaintext, err := c.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("Decrypt(%q): %v", ciphertext, err)
+		}
+
+		if plaintext != secret {
+			t.Errorf("Decrypt(%q) = %q, want %q", ciphertext, plaintext, secret)
+		}
+	}
+}
+
+func TestCipherDecryptInvalid(t *testing.T) {
+	c, err := NewCipher([]byte("test key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewCipher([]byte("other key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := other.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own, err := c.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a bit of the authentication tag flipped
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(own, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	altered := prefix + base64.RawURLEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name       string
+		ciphertext string
+	}{
+		{name: "plaintext", ciphertext: "GEZDGNBVGY3TQOJQ"},
+		{name: "other key", ciphertext: encrypted},
+		{name: "altered", ciphertext: altered},
+		{name: "not base64", ciphertext: "v1:!!!"},
+		{name: "too short", ciphertext: "v1:AAAA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decrypt(tt.ciphertext); !errors.Is(err, ErrInvalidCiphertext) {
+				t.Errorf("Decrypt error = %v, want %v", err, ErrInvalidCiphertext)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package hash
+
+// Scheme is a single password hashing algorithm.
+type Scheme interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// Supports reports whether encoded was produced by the scheme.
+	Supports(encoded string) bool
+	// NeedsRehash reports whether encoded was produced with outdated parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// Hasher hashes new passwords with the preferred scheme and verifies hashes made by
+// any of the known schemes, so stored hashes can be migrated one sign-in at a time.
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+}
+
+func NewHasher(preferred Scheme, legacy ...Scheme) *Hasher {
+	return &Hasher{
+		preferred: preferred,
+		schemes:   append([]Scheme{preferred}, legacy...),
+	}
+}
+
+func (h Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify checks the password against the encoded hash. Hashes of unknown schemes never match.
+func (h Hasher) Verify(password, encoded string) (bool, error) {
+	for _, scheme := range h.schemes {
+		if scheme.Supports(encoded) {
+			return scheme.Verify(password, encoded)
+		}
+	}
+
+	return false, nil
+}
+
+// NeedsRehash reports whether encoded should be replaced by a hash of the preferred scheme.
+func (h Hasher) NeedsRehash(encoded string) bool {
+	if !h.preferred.Supports(encoded) {
+		return true
+	}
+
+	return h.preferred.NeedsRehash(encoded)
+}
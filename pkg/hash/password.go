@@ -2,9 +2,13 @@ package hash
 
 import (
 	"crypto/md5"
+	"crypto/subtle"
 	"fmt"
+	"strings"
 )
 
+// MD5Hasher is the legacy password scheme. It's kept only to verify the hashes
+// stored before the adaptive schemes were introduced, which are rehashed on the next sign-in.
 type MD5Hasher struct {
 	salt string
 }
@@ -22,3 +26,21 @@ func (h MD5Hasher) Hash(password string) (string, error) {
 
 	return fmt.Sprintf("%x", md5h.Sum([]byte(h.salt))), nil
 }
+
+func (h MD5Hasher) Verify(password, encoded string) (bool, error) {
+	hash, err := h.Hash(password)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(encoded)) == 1, nil
+}
+
+// Supports reports whether encoded looks like a legacy hash: plain hex without a scheme prefix.
+func (h MD5Hasher) Supports(encoded string) bool {
+	return encoded != "" && !strings.HasPrefix(encoded, "$")
+}
+
+func (h MD5Hasher) NeedsRehash(string) bool {
+	return true
+}
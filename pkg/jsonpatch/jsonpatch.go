@@ -0,0 +1,439 @@
+// Package jsonpatch applies JSON Patch (RFC 6902) and JSON Merge Patch (RFC 7396) documents
+// to JSON documents. Patches are applied as a whole: if an operation fails, the document is not changed.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for patches that are not well-formed.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPathNotFound is returned when an operation refers to a location the document lacks.
+	ErrPathNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when the value of a test operation doesn't match the document.
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// Operation is a single operation of a JSON Patch.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies the JSON Patch to the document and returns the patched document.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	node, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if node, err = apply(node, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(node)
+}
+
+// MergePatch applies the merge patch to the document and returns the patched document:
+// members of the patch replace the members of the document, null members remove them.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+
+		t[k] = merge(t[k], v)
+	}
+
+	return t
+}
+
+func apply(node interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return add(node, path, value)
+		case "replace":
+			return replace(node, path, value)
+		default:
+			return node, test(node, path, value)
+		}
+	case "remove":
+		return remove(node, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(node, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			return add(node, path, deepCopy(value))
+		}
+
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+		}
+
+		if node, err = remove(node, from); err != nil {
+			return nil, err
+		}
+
+		return add(node, path, value)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+func add(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return walk(node, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			if key == "-" {
+				return append(p, value), nil
+			}
+
+			i, err := index(key, len(p)+1)
+			if err != nil {
+				return nil, err
+			}
+
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+
+			return p, nil
+		default:
+			return nil, ErrPathNotFound
+		}
+	})
+}
+
+func remove(node interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	return walk(node, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, ErrPathNotFound
+			}
+
+			delete(p, key)
+
+			return p, nil
+		case []interface{}:
+			i, err := index(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+
+			return append(p[:i], p[i+1:]...), nil
+		default:
+			return nil, ErrPathNotFound
+		}
+	})
+}
+
+func replace(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return walk(node, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, ErrPathNotFound
+			}
+
+			p[key] = value
+
+			return p, nil
+		case []interface{}:
+			i, err := index(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+
+			p[i] = value
+
+			return p, nil
+		default:
+			return nil, ErrPathNotFound
+		}
+	})
+}
+
+func test(node interface{}, path []string, value interface{}) error {
+	actual, err := get(node, path)
+	if err != nil {
+		return err
+	}
+
+	if !equal(actual, value) {
+		return ErrTestFailed
+	}
+
+	return nil
+}
+
+// walk descends to the parent of the location and lets change modify it. The containers
+// on the way are updated with the changed children, as appending to an array may move it.
+func walk(node interface{}, path []string, change func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+
+		child, err := walk(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+
+		n[path[0]] = child
+
+		return n, nil
+	case []interface{}:
+		i, err := index(path[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := walk(n[i], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+
+		n[i] = child
+
+		return n, nil
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, key := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[key]
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+
+			node = child
+		case []interface{}:
+			i, err := index(key, len(n))
+			if err != nil {
+				return nil, err
+			}
+
+			node = n[i]
+		default:
+			return nil, ErrPathNotFound
+		}
+	}
+
+	return node, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into the unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: pointer %q doesn't start with /", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// index parses an array index, which has to be below the limit.
+func index(key string, limit int) (int, error) {
+	if key == "" || len(key) > 1 && key[0] == '0' {
+		return 0, ErrPathNotFound
+	}
+
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= limit {
+		return 0, ErrPathNotFound
+	}
+
+	return i, nil
+}
+
+func decode(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("%w: value is missing", ErrInvalidPatch)
+	}
+
+	return decode(raw)
+}
+
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+
+		return errA == nil && errB == nil && x == y
+	default:
+		return a == b
+	}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = deepCopy(e)
+		}
+
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = deepCopy(e)
+		}
+
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,261 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// mostly the examples of RFC 6902 appendix A
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "add an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "add an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "append to an array",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			name:  "add a nested member",
+			doc:   `{"foo": {"bar": 1}}`,
+			patch: `[{"op": "add", "path": "/foo/baz", "value": {"x": null}}]`,
+			want:  `{"foo": {"bar": 1, "baz": {"x": null}}}`,
+		},
+		{
+			name:    "add to a missing parent",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			wantErr: ErrPathNotFound,
+		},
+		{
+			name:    "add past the end of an array",
+			doc:     `{"foo": ["bar"]}`,
+			patch:   `[{"op": "add", "path": "/foo/2", "value": "qux"}]`,
+			wantErr: ErrPathNotFound,
+		},
+		{
+			name:  "remove an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "remove an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:    "remove a missing member",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "remove", "path": "/baz"}]`,
+			wantErr: ErrPathNotFound,
+		},
+		{
+			name:    "remove the whole document",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "remove", "path": ""}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "replace a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:    "replace a missing member",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			wantErr: ErrPathNotFound,
+		},
+		{
+			name:  "replace the whole document",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "", "value": [1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "move a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "move an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name:    "move a value into itself",
+			doc:     `{"foo": {"bar": 1}}`,
+			patch:   `[{"op": "move", "from": "/foo", "path": "/foo/bar/baz"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "copy a value",
+			doc:   `{"foo": {"bar": [1]}}`,
+			patch: `[{"op": "copy", "from": "/foo/bar", "path": "/baz"}, {"op": "add", "path": "/baz/-", "value": 2}]`,
+			want:  `{"foo": {"bar": [1]}, "baz": [1, 2]}`,
+		},
+		{
+			name:  "test passes",
+			doc:   `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			want:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:    "test fails",
+			doc:     `{"baz": "qux"}`,
+			patch:   `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:  "test compares numbers by value",
+			doc:   `{"year": 1994}`,
+			patch: `[{"op": "test", "path": "/year", "value": 1994.0}]`,
+			want:  `{"year": 1994}`,
+		},
+		{
+			name:  "test compares objects ignoring member order",
+			doc:   `{"foo": {"a": 1, "b": [true, null]}}`,
+			patch: `[{"op": "test", "path": "/foo", "value": {"b": [true, null], "a": 1}}]`,
+			want:  `{"foo": {"a": 1, "b": [true, null]}}`,
+		},
+		{
+			name:    "failed test stops the patch",
+			doc:     `{"year": 2004}`,
+			patch:   `[{"op": "test", "path": "/year", "value": 1994}, {"op": "replace", "path": "/year", "value": 1994}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:  "escaped pointer",
+			doc:   `{"a/b": 1, "m~n": 2}`,
+			patch: `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "remove", "path": "/m~0n"}]`,
+			want:  `{"a/b": 3}`,
+		},
+		{
+			name:    "array index with a leading zero",
+			doc:     `{"foo": ["a", "b"]}`,
+			patch:   `[{"op": "replace", "path": "/foo/01", "value": "c"}]`,
+			wantErr: ErrPathNotFound,
+		},
+		{
+			name:    "pointer without a slash",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "replace", "path": "foo", "value": "baz"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "value missing",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "add", "path": "/baz"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "unknown operation",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "increment", "path": "/foo"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "patch is not an array",
+			doc:     `{"foo": "bar"}`,
+			patch:   `{"op": "remove", "path": "/foo"}`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Apply error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7396 appendix A
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{doc: `{"a": "b"}`, patch: `{"a": "c"}`, want: `{"a": "c"}`},
+		{doc: `{"a": "b"}`, patch: `{"b": "c"}`, want: `{"a": "b", "b": "c"}`},
+		{doc: `{"a": "b"}`, patch: `{"a": null}`, want: `{}`},
+		{doc: `{"a": "b", "b": "c"}`, patch: `{"a": null}`, want: `{"b": "c"}`},
+		{doc: `{"a": ["b"]}`, patch: `{"a": "c"}`, want: `{"a": "c"}`},
+		{doc: `{"a": "c"}`, patch: `{"a": ["b"]}`, want: `{"a": ["b"]}`},
+		{doc: `{"a": {"b": "c"}}`, patch: `{"a": {"b": "d", "c": null}}`, want: `{"a": {"b": "d"}}`},
+		{doc: `{"a": [{"b": "c"}]}`, patch: `{"a": [1]}`, want: `{"a": [1]}`},
+		{doc: `["a", "b"]`, patch: `["c", "d"]`, want: `["c", "d"]`},
+		{doc: `{"a": "b"}`, patch: `["c"]`, want: `["c"]`},
+		{doc: `{"a": "foo"}`, patch: `null`, want: `null`},
+		{doc: `{"a": "foo"}`, patch: `"bar"`, want: `"bar"`},
+		{doc: `{"e": null}`, patch: `{"a": 1}`, want: `{"e": null, "a": 1}`},
+		{doc: `[1, 2]`, patch: `{"a": "b", "c": null}`, want: `{"a": "b"}`},
+		{doc: `{}`, patch: `{"a": {"bb": {"ccc": null}}}`, want: `{"a": {"bb": {}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+		}
+
+		assertJSONEqual(t, got, tt.want)
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a": "b"}`), []byte(`{"a":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("MergePatch error = %v, want %v", err, ErrInvalidPatch)
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("patched document %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expected document %s: %v", want, err)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("patched document = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key type, RSA and Ed25519 keys are supported")
+	ErrUnknownKey     = errors.New("unknown key id")
+)
+
+// Key is a public key tokens are verified with. ID is the RFC 7638 thumbprint of the key,
+// so it stays the same wherever the key is loaded.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+}
+
+// KeySet signs tokens with a single private key and verifies them with any of its public keys.
+// Rotation is done by adding the new signing key while the previous one stays a verification
+// key until the last token it signed expires.
+type KeySet struct {
+	signer  crypto.Signer
+	signing Key
+	keys    map[string]Key
+}
+
+func NewKeySet(signer crypto.Signer, verification ...crypto.PublicKey) (*KeySet, error) {
+	signing, err := newKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		signer:  signer,
+		signing: signing,
+		keys:    map[string]Key{signing.ID: signing},
+	}
+
+	for _, pub := range verification {
+		key, err := newKey(pub)
+		if err != nil {
+			return nil, err
+		}
+
+		ks.keys[key.ID] = key
+	}
+
+	return ks, nil
+}
+
+// LoadKeySet reads the PEM encoded signing private key and verification public keys from files.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles ...string) (*KeySet, error) {
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", signingKeyFile, err)
+	}
+
+	verification := make([]crypto.PublicKey, 0, len(verificationKeyFiles))
+	for _, file := range verificationKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		pub, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		verification = append(verification, pub)
+	}
+
+	return NewKeySet(signer, verification...)
+}
+
+// GenerateKeySet creates a key set with a fresh Ed25519 key. Tokens signed with it
+// don't survive a restart, so it's meant for local development only.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(private)
+}
+
+// Sign signs the claims with the signing key and sets its id as the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(ks.signing.Method, claims)
+	t.Header["kid"] = ks.signing.ID
+
+	return t.SignedString(ks.signer)
+}
+
+// Keyfunc finds the verification key of a token by its kid header, see jwt.Keyfunc.
+func (ks *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpecting signing method %v", t.Header["alg"])
+	}
+
+	return key.Public, nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (ks *KeySet) JWKS() Set {
+	set := Set{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+
+	// the signing key goes first, clients picking the first key get the right one
+	set.Keys = append(set.Keys, toJSONWebKey(ks.signing))
+	for id, key := range ks.keys {
+		if id != ks.signing.ID {
+			set.Keys = append(set.Keys, toJSONWebKey(key))
+		}
+	}
+
+	return set
+}
+
+// Set is a JSON Web Key Set, RFC 7517.
+type Set struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func toJSONWebKey(key Key) JSONWebKey {
+	jwk := publicJWK(key.Public)
+	jwk.Use = "sig"
+	jwk.Alg = key.Method.Alg()
+	jwk.Kid = key.ID
+
+	return jwk
+}
+
+// publicJWK returns the key type specific members of the public key.
+func publicJWK(pub crypto.PublicKey) JSONWebKey {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return JSONWebKey{}
+	}
+}
+
+func newKey(pub crypto.PublicKey) (Key, error) {
+	var (
+		method jwt.SigningMethod
+		// members required by RFC 7638, in lexicographic order
+		thumbprint interface{}
+	)
+
+	jwk := publicJWK(pub)
+
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+		thumbprint = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		thumbprint = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+
+	data, err := json.Marshal(thumbprint)
+	if err != nil {
+		return Key{}, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return Key{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:]),
+		Method: method,
+		Public: pub,
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
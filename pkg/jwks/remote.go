@@ -0,0 +1,209 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// refreshInterval limits how often the keys are fetched again for an unknown kid,
+// so tokens with made up key ids can't make us hammer the provider.
+const refreshInterval = time.Minute
+
+// RemoteKeySet verifies tokens with the keys published by another party at a JWKS URL.
+// The keys are fetched on first use and again when a token names a key that isn't known yet,
+// which is how the publisher's key rotation is picked up.
+type RemoteKeySet struct {
+	client *http.Client
+	url    string
+
+	mu        sync.Mutex
+	keys      map[string]JSONWebKey
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(client *http.Client, url string) *RemoteKeySet {
+	return &RemoteKeySet{client: client, url: url}
+}
+
+// KeyfuncContext returns a jwt.Keyfunc that fetches the keys within ctx.
+func (ks *RemoteKeySet) KeyfuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		jwk, err := ks.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if !jwk.allows(t.Method.Alg()) {
+			return nil, fmt.Errorf("unexpecting signing method %v", t.Header["alg"])
+		}
+
+		return jwk.PublicKey()
+	}
+}
+
+func (ks *RemoteKeySet) key(ctx context.Context, kid string) (JSONWebKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if jwk, ok := ks.lookup(kid); ok {
+		return jwk, nil
+	}
+
+	if time.Since(ks.fetchedAt) < refreshInterval {
+		return JSONWebKey{}, ErrUnknownKey
+	}
+
+	if err := ks.fetch(ctx); err != nil {
+		return JSONWebKey{}, err
+	}
+
+	if jwk, ok := ks.lookup(kid); ok {
+		return jwk, nil
+	}
+
+	return JSONWebKey{}, ErrUnknownKey
+}
+
+// lookup finds the key by id. A token without kid is accepted only while the set has a single key.
+func (ks *RemoteKeySet) lookup(kid string) (JSONWebKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, jwk := range ks.keys {
+			return jwk, true
+		}
+	}
+
+	jwk, ok := ks.keys[kid]
+
+	return jwk, ok
+}
+
+func (ks *RemoteKeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching jwks: unexpected status %s", resp.Status)
+	}
+
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	ks.keys = make(map[string]JSONWebKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use == "" || jwk.Use == "sig" {
+			ks.keys[jwk.Kid] = jwk
+		}
+	}
+	ks.fetchedAt = time.Now()
+
+	return nil
+}
+
+// allows reports whether the key may verify tokens signed with alg.
+func (k JSONWebKey) allows(alg string) bool {
+	if k.Alg != "" {
+		return k.Alg == alg
+	}
+
+	switch k.Kty {
+	case "RSA":
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case "EC":
+		return strings.HasPrefix(alg, "ES")
+	case "OKP":
+		return alg == jwt.SigningMethodEdDSA.Alg()
+	default:
+		return false
+	}
+}
+
+// PublicKey decodes the public key of the JWK. RSA, EC (P-256, P-384, P-521) and Ed25519 keys are supported.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKey
+		}
+
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrUnsupportedKey
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender drops every message into a directory as an .eml file instead of sending it.
+type FileSender struct {
+	dir  string
+	from string
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+func (s FileSender) Send(_ context.Context, msg Message) error {
+	msg.From = s.from
+
+	// the recipient is user input, only its hash goes into the file name
+	sum := sha256.Sum256([]byte(msg.To))
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), hex.EncodeToString(sum[:8]))
+
+	return os.WriteFile(filepath.Join(s.dir, name), msg.Bytes(), 0o644)
+}
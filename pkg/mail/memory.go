@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// MemorySender keeps the messages in memory, it's meant for tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	from     string
+	messages []Message
+}
+
+func NewMemorySender(from string) *MemorySender {
+	return &MemorySender{from: from}
+}
+
+func (s *MemorySender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.From = s.from
+	s.messages = append(s.messages, msg)
+
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
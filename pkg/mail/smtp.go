@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+)
+
+// SMTPSender sends messages through an SMTP server.
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender creates a sender for the server at host:port. The authentication is skipped
+// when username is empty.
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (s SMTPSender) Send(_ context.Context, msg Message) error {
+	msg.From = s.from
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, msg.Bytes())
+}
@@ -0,0 +1,220 @@
+// Package oidc is a relying party of OpenID Connect providers: the authorization code flow
+// with PKCE (RFC 7636) and the validation of ID tokens. The provider is configured by
+// the URL of its discovery document.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lukinairina90/crud_movies/pkg/jwks"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+type Config struct {
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Discovery is the part of the provider metadata the client uses.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims the client reads.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	AuthorizedBy  string `json:"azp"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Client talks to a single provider. The discovery document is fetched on first use,
+// so the application starts even when the provider is down.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *jwks.RemoteKeySet
+}
+
+func NewClient(cfg Config, httpClient *http.Client) *Client {
+	return &Client{cfg: cfg, httpClient: httpClient}
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The state and nonce tie the
+// callback to this login, the verifier is sent with the code to prove the same client asks.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, _, err := c.provider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.cfg.ClientID)
+	v.Set("redirect_uri", c.cfg.RedirectURL)
+	v.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the validated claims of the ID token.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	d, keys, err := c.provider(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	// public clients have no secret and identify themselves in the form
+	if c.cfg.ClientSecret == "" {
+		form.Set("client_id", c.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return Claims{}, fmt.Errorf("token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token request failed: %s %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+
+	return c.verify(ctx, d, keys, token.IDToken, nonce)
+}
+
+// verify validates the ID token as OpenID Connect Core 3.1.3.7 requires.
+func (c *Client) verify(ctx context.Context, d *Discovery, keys *jwks.RemoteKeySet, idToken, nonce string) (Claims, error) {
+	var claims Claims
+	t, err := jwt.ParseWithClaims(idToken, &claims, keys.KeyfuncContext(ctx))
+	if err != nil || !t.Valid {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	switch {
+	case claims.Issuer != d.Issuer:
+		return Claims{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(c.cfg.ClientID, true):
+		return Claims{}, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != c.cfg.ClientID:
+		return Claims{}, fmt.Errorf("%w: unexpected azp %q", ErrInvalidIDToken, claims.AuthorizedBy)
+	case claims.ExpiresAt == nil:
+		return Claims{}, fmt.Errorf("%w: no expiry", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func (c *Client) provider(ctx context.Context) (*Discovery, *jwks.RemoteKeySet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.discovery != nil {
+		return c.discovery, c.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.DiscoveryURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetching discovery document: unexpected status %s", resp.Status)
+	}
+
+	var d Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, nil, fmt.Errorf("fetching discovery document: %w", err)
+	}
+
+	if d.Issuer == "" || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, nil, errors.New("discovery document lacks required endpoints")
+	}
+
+	c.discovery = &d
+	c.keys = jwks.NewRemoteKeySet(c.httpClient, d.JWKSURI)
+
+	return c.discovery, c.keys, nil
+}
+
+// RandomString returns a random URL safe string, used for state, nonce and PKCE verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge derives the S256 code challenge from the PKCE verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
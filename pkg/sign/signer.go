@@ -0,0 +1,63 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer encodes values into opaque strings protected by an HMAC-SHA256 signature,
+// so that clients can hold them without being able to forge or alter them.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+func (s Signer) Encode(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), nil
+}
+
+func (s Signer) Decode(token string, v interface{}) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal(mac, s.sign(encoded)) {
+		return ErrInvalidSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+func (s Signer) sign(data string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the RFC 6238 appendix B values, cut to the last 6 of their 8 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+
+		if code != tt.code {
+			t.Errorf("Code(%d) = %q, want %q", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+	code, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Step(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != "287082" {
+		t.Errorf("Code = %q, want %q", code, "287082")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step", code: codeAt(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "next step", code: codeAt(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "two steps ago", code: codeAt(current - 2)},
+		{name: "two steps ahead", code: codeAt(current + 2)},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: codeAt(current)[:Digits-1]},
+		{name: "too long", code: codeAt(current) + "0"},
+		{name: "empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a code of a step outside the window may collide with one inside it
+			if !tt.wantOK && tt.code != "" {
+				for step := current - Skew; step <= current+Skew; step++ {
+					if codeAt(step) == tt.code {
+						t.Skip("the code collides with a code of the window")
+					}
+				}
+			}
+
+			step, ok, err := Validate(rfcSecret, tt.code, now)
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate = (%d, %t), want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, _, err := Validate("not base32!", "123456", time.Now()); err == nil {
+		t.Error("Validate with an invalid secret: want error")
+	}
+}